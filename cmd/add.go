@@ -5,9 +5,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
 )
 
 type addFlag struct {
@@ -16,6 +19,8 @@ type addFlag struct {
 	priority string
 	due      *string
 	tag      *string
+	project  string
+	estimate int // seconds
 }
 
 func (a *addFlag) GetStatus() string    { return a.status }
@@ -30,10 +35,15 @@ func parseAdd() *addFlag {
 	parse := service.Parse(fs, "add")
 
 	if len(parse.NonFlagArgs) == 0 {
-		fmt.Println("usage: todo add \"task text\" [--status=STATUS] [--priority=PRIORITY] [--due=DATE] [--tag=TAG]")
+		fmt.Println("usage: todo add \"task text\" [--status=STATUS] [--priority=PRIORITY] [--due=DATE] [--tag=TAG] [--project=PROJECT] [--estimate=DURATION]")
 		os.Exit(1)
 	}
 	text := parse.NonFlagArgs[0]
+	estimate, err := service.ParseEstimate(*parse.Estimate)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
   var due string
   if parse.Due != nil {
     due = strings.ToLower(*parse.Due)
@@ -44,19 +54,32 @@ func parseAdd() *addFlag {
 		priority: strings.ToLower(*parse.Priority),
 		due:      &due,
 		tag:      parse.Tag,
+		project:  *parse.Project,
+		estimate: estimate,
 	}
 }
 
 func (app *App) save(cmd *addFlag) error {
 	if cmd.status == "" {
-		cmd.status = "1" // pending
+		cmd.status = strconv.Itoa(config.Get().NewStatus().Value)
 	}
 	if cmd.priority == "" {
-		cmd.priority = "2" // medium
+		cmd.priority = strconv.Itoa(config.Get().NewPriority().Weight)
+	}
+	fields := map[string]any{
+		"text":     cmd.text,
+		"status":   cmd.status,
+		"priority": cmd.priority,
+		"due":      cmd.due,
+		"tag":      cmd.tag,
+	}
+	if cmd.project != "" {
+		fields["project"] = cmd.project
+	}
+	if cmd.estimate > 0 {
+		fields["estimate"] = cmd.estimate
 	}
-	_, err := app.db.Exec(`
-    insert into todos(text, status, priority, due, tag) values(?,?,?,?,?)
-  `, cmd.text, cmd.status, cmd.priority, &cmd.due, cmd.tag)
+	_, err := db.Insert(app.db, fields, db.SourceCLI)
 	return err
 }
 
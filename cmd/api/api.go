@@ -0,0 +1,270 @@
+// Package api serves the todos over HTTP as JSON. Writes go through the
+// same store as the CLI and are recorded in the history with source api.
+// Every task has an ETag, its updated_at in milliseconds, and writes with
+// an If-Match that is not the current one fail with 412.
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/benpsk/todo/db"
+)
+
+//go:embed openapi.json
+var openapi []byte
+
+type server struct {
+	db    *sql.DB
+	token string
+}
+
+// New returns the API handler. A token, when not empty, is required as
+// "Authorization: Bearer TOKEN" on every request but the spec.
+func New(conn *sql.DB, token string) http.Handler {
+	s := &server{db: conn, token: token}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openapi)
+	})
+	mux.HandleFunc("GET /tasks", s.auth(s.list))
+	mux.HandleFunc("POST /tasks", s.auth(s.create))
+	mux.HandleFunc("GET /tasks/{id}", s.auth(s.get))
+	mux.HandleFunc("PATCH /tasks/{id}", s.auth(s.update(false)))
+	mux.HandleFunc("PUT /tasks/{id}", s.auth(s.update(true)))
+	mux.HandleFunc("DELETE /tasks/{id}", s.auth(s.delete))
+	return mux
+}
+
+// CheckAddr refuses an addr other machines can reach when there is no
+// token, since the API writes to the todos.
+func CheckAddr(addr, token string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if token != "" || host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("%s is reachable from other machines, set a token or listen on 127.0.0.1", addr)
+}
+
+func (s *server) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			given, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !found || subtle.ConstantTimeCompare([]byte(given), []byte(s.token)) != 1 {
+				writeError(w, http.StatusUnauthorized, "missing or wrong token")
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	f, problems := FilterOf(r.URL.Query())
+	if len(problems) > 0 {
+		writeError(w, http.StatusBadRequest, strings.Join(problems, "; "))
+		return
+	}
+	tasks, err := List(s.db, f)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, tasks)
+}
+
+func (s *server) get(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.task(w, r)
+	if !ok {
+		return
+	}
+	if match(r.Header.Get("If-None-Match"), t.version) {
+		w.Header().Set("ETag", etag(t.version))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeTask(w, http.StatusOK, t)
+}
+
+func (s *server) create(w http.ResponseWriter, r *http.Request) {
+	var in Input
+	if !decode(w, r, &in) {
+		return
+	}
+	if in.Text == nil || strings.TrimSpace(*in.Text) == "" {
+		writeError(w, http.StatusBadRequest, "text is required")
+		return
+	}
+	fields, problems := in.Fields(true)
+	if len(problems) > 0 {
+		writeError(w, http.StatusBadRequest, strings.Join(problems, "; "))
+		return
+	}
+	id, err := db.Insert(s.db, fields, db.SourceAPI)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	t, err := Load(s.db, id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Location", fmt.Sprintf("/tasks/%d", id))
+	writeTask(w, http.StatusCreated, t)
+}
+
+// update patches the given fields, or with replace sets every field and
+// clears the ones not given.
+func (s *server) update(replace bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		current, ok := s.task(w, r)
+		if !ok {
+			return
+		}
+		version, ok := precondition(w, r, current)
+		if !ok {
+			return
+		}
+		var in Input
+		if !decode(w, r, &in) {
+			return
+		}
+		if replace && (in.Text == nil || strings.TrimSpace(*in.Text) == "") {
+			writeError(w, http.StatusBadRequest, "text is required")
+			return
+		}
+		fields, problems := in.Fields(replace)
+		if len(problems) > 0 {
+			writeError(w, http.StatusBadRequest, strings.Join(problems, "; "))
+			return
+		}
+		if err := Change(s.db, current, version, fields, db.SourceAPI); err != nil {
+			switch {
+			case errors.Is(err, ErrTransition):
+				writeError(w, http.StatusBadRequest, err.Error())
+			case errors.Is(err, db.ErrModified):
+				writeError(w, http.StatusPreconditionFailed, "task was modified, get it again")
+			default:
+				writeError(w, http.StatusInternalServerError, err.Error())
+			}
+			return
+		}
+		t, err := Load(s.db, current.ID)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeTask(w, http.StatusOK, t)
+	}
+}
+
+func (s *server) delete(w http.ResponseWriter, r *http.Request) {
+	current, ok := s.task(w, r)
+	if !ok {
+		return
+	}
+	version, ok := precondition(w, r, current)
+	if !ok {
+		return
+	}
+	if err := db.DeleteVersion(s.db, current.ID, version, db.SourceAPI); err != nil {
+		if errors.Is(err, db.ErrModified) {
+			writeError(w, http.StatusPreconditionFailed, "task was modified, get it again")
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// task loads the task of the {id} in the path, writing the error when
+// there is none.
+func (s *server) task(w http.ResponseWriter, r *http.Request) (Task, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid id %q", r.PathValue("id")))
+		return Task{}, false
+	}
+	t, err := Load(s.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no task with id %d", id))
+		return Task{}, false
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return Task{}, false
+	}
+	return t, true
+}
+
+// precondition returns the version a write is based on, the If-Match or
+// else the current one. It writes 412 when the If-Match is not current.
+func precondition(w http.ResponseWriter, r *http.Request, t Task) (int64, bool) {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || ifMatch == "*" {
+		return t.version, true
+	}
+	if !match(ifMatch, t.version) {
+		w.Header().Set("ETag", etag(t.version))
+		writeError(w, http.StatusPreconditionFailed, "task was modified, get it again")
+		return 0, false
+	}
+	return t.version, true
+}
+
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// match reports whether a list of ETags has version.
+func match(header string, version int64) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag(version) || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeTask(w http.ResponseWriter, code int, t Task) {
+	w.Header().Set("ETag", etag(t.version))
+	writeJSON(w, code, t)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
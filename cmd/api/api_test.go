@@ -0,0 +1,116 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/benpsk/todo/db"
+)
+
+// serve returns the API on a new database with task 1 and its ETag.
+func serve(t *testing.T) (http.Handler, *sql.DB, string) {
+	t.Helper()
+	conn, err := db.Connect(filepath.Join(t.TempDir(), "todos.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	if _, err := db.Insert(conn, map[string]any{"text": "Buy milk"}, db.SourceCLI); err != nil {
+		t.Fatal(err)
+	}
+	h := New(conn, "")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/tasks/1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /tasks/1 = %d: %s", rec.Code, rec.Body)
+	}
+	return h, conn, rec.Header().Get("ETag")
+}
+
+func TestPreconditions(t *testing.T) {
+	const stale = `"1"`
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		header string
+		// value is the header value, given the current ETag
+		value func(current string) string
+		code  int
+	}{
+		{"get current", "GET", "", "If-None-Match", func(c string) string { return c }, http.StatusNotModified},
+		{"get weak current", "GET", "", "If-None-Match", func(c string) string { return "W/" + c }, http.StatusNotModified},
+		{"get any", "GET", "", "If-None-Match", func(string) string { return "*" }, http.StatusNotModified},
+		{"get stale", "GET", "", "If-None-Match", func(string) string { return stale }, http.StatusOK},
+		{"patch without", "PATCH", `{"text":"Buy oat milk"}`, "", nil, http.StatusOK},
+		{"patch current", "PATCH", `{"text":"Buy oat milk"}`, "If-Match", func(c string) string { return c }, http.StatusOK},
+		{"patch any", "PATCH", `{"text":"Buy oat milk"}`, "If-Match", func(string) string { return "*" }, http.StatusOK},
+		{"patch list with current", "PATCH", `{"text":"Buy oat milk"}`, "If-Match", func(c string) string { return stale + ", " + c }, http.StatusOK},
+		{"patch stale", "PATCH", `{"text":"Buy oat milk"}`, "If-Match", func(string) string { return stale }, http.StatusPreconditionFailed},
+		{"put stale", "PUT", `{"text":"Buy oat milk"}`, "If-Match", func(string) string { return stale }, http.StatusPreconditionFailed},
+		{"delete current", "DELETE", "", "If-Match", func(c string) string { return c }, http.StatusNoContent},
+		{"delete stale", "DELETE", "", "If-Match", func(string) string { return stale }, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, conn, current := serve(t)
+			if current == "" {
+				t.Fatal("no ETag")
+			}
+			req := httptest.NewRequest(tt.method, "/tasks/1", strings.NewReader(tt.body))
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value(current))
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code != tt.code {
+				t.Fatalf("%s = %d, want %d: %s", tt.method, rec.Code, tt.code, rec.Body)
+			}
+
+			var text string
+			err := conn.QueryRow("select text from todos where id = 1").Scan(&text)
+			switch rec.Code {
+			case http.StatusPreconditionFailed:
+				// the client learns the current version and nothing is written
+				if got := rec.Header().Get("ETag"); got != current {
+					t.Errorf("ETag = %s, want the current %s", got, current)
+				}
+				if err != nil || text != "Buy milk" {
+					t.Errorf("text = %q, %v, want it unchanged", text, err)
+				}
+			case http.StatusOK:
+				if tt.method == "PATCH" {
+					if got := rec.Header().Get("ETag"); got == current || got == "" {
+						t.Errorf("ETag = %s, want a new one", got)
+					}
+					if text != "Buy oat milk" {
+						t.Errorf("text = %q, want it written", text)
+					}
+				}
+			case http.StatusNoContent:
+				if err != sql.ErrNoRows {
+					t.Errorf("task still there: %q, %v", text, err)
+				}
+			}
+		})
+	}
+}
+
+// TestLostUpdate has two clients change the same version, the second
+// one is refused.
+func TestLostUpdate(t *testing.T) {
+	h, _, current := serve(t)
+	for i, want := range []int{http.StatusOK, http.StatusPreconditionFailed} {
+		req := httptest.NewRequest("PATCH", "/tasks/1", strings.NewReader(`{"priority":"high"}`))
+		req.Header.Set("If-Match", current)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("write %d = %d, want %d: %s", i+1, rec.Code, want, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+// Task is a todo as the API returns it. Status and priority are names.
+type Task struct {
+	ID         int        `json:"id"`
+	UUID       string     `json:"uuid"`
+	Text       string     `json:"text"`
+	Status     string     `json:"status"`
+	Priority   string     `json:"priority"`
+	Due        *string    `json:"due"` // 2006-01-02 or 2006-01-02 15:04
+	Tags       []string   `json:"tags"`
+	Project    *string    `json:"project"`
+	Estimate   int        `json:"estimate"` // seconds
+	Logged     int        `json:"logged"`   // seconds
+	Recur      *string    `json:"recur"`    // RRULE of an imported task, read only
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ArchivedAt *time.Time `json:"archived_at"`
+
+	version int64
+}
+
+const taskColumns = `
+    id, coalesce(uuid, ''), text, status, priority, due || '', coalesce(tag, ''), project,
+    coalesce(estimate, 0), ` + service.LoggedQuery + `, recur, created_at, updated_at, archived_at,
+    ` + db.VersionQuery
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanTask(row scanner) (Task, error) {
+	var t Task
+	var status, priority int
+	var due *string
+	var tags string
+	err := row.Scan(&t.ID, &t.UUID, &t.Text, &status, &priority, &due, &tags, &t.Project,
+		&t.Estimate, &t.Logged, &t.Recur, &t.CreatedAt, &t.UpdatedAt, &t.ArchivedAt, &t.version)
+	if err != nil {
+		return t, err
+	}
+	cfg := config.Get()
+	t.Status = strconv.Itoa(status)
+	if s, exists := cfg.Status(t.Status); exists {
+		t.Status = s.Name
+	}
+	t.Priority = strconv.Itoa(priority)
+	if p, exists := cfg.Priority(t.Priority); exists {
+		t.Priority = p.Name
+	}
+	if due != nil {
+		*due = db.Due(*due)
+	}
+	t.Due = due
+	t.Tags = []string{}
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			t.Tags = append(t.Tags, tag)
+		}
+	}
+	if t.ArchivedAt != nil {
+		local := db.Local(*t.ArchivedAt)
+		t.ArchivedAt = &local
+	}
+	return t, nil
+}
+
+// Version is the version the ETag of the task is made of.
+func (t Task) Version() int64 {
+	return t.version
+}
+
+// Load returns the task with id, sql.ErrNoRows when there is none.
+func Load(conn *sql.DB, id int) (Task, error) {
+	return scanTask(conn.QueryRow("SELECT "+taskColumns+" FROM todos WHERE id = ?", id))
+}
+
+// List returns the tasks matching f, every one that is not archived
+// when f is empty.
+func List(conn *sql.DB, f *service.Filter) ([]Task, error) {
+	where, args := f.Where()
+	query := "SELECT " + taskColumns + " FROM todos" + where
+	if f.Archived {
+		query += " AND archived_at IS NOT NULL"
+	} else {
+		query += " AND archived_at IS NULL"
+	}
+	query += " ORDER BY priority DESC, id"
+	rows, err := conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tasks := []Task{}
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
+
+// Input is the body of a create or update. Fields left out are not
+// changed, "" or [] clears them.
+type Input struct {
+	Text     *string   `json:"text"`
+	Status   *string   `json:"status"`
+	Priority *string   `json:"priority"`
+	Due      *string   `json:"due"` // as the --due flag, or 2006-01-02 15:04
+	Tags     *[]string `json:"tags"`
+	Project  *string   `json:"project"`
+	Estimate *int      `json:"estimate"` // seconds
+}
+
+// due resolves a due date for Check.
+type due struct{ value *string }
+
+func (d *due) GetStatus() string    { return "" }
+func (d *due) SetStatus(string)     {}
+func (d *due) GetPriority() string  { return "" }
+func (d *due) SetPriority(string)   {}
+func (d *due) GetDue() *string      { return d.value }
+func (d *due) SetDue(value *string) { d.value = value }
+
+// Fields returns the todos columns to write. With all, fields left out
+// are cleared or set to their default.
+func (in Input) Fields(all bool) (map[string]any, []string) {
+	cfg := config.Get()
+	fields := map[string]any{}
+	var problems []string
+	if all {
+		fields["status"] = cfg.NewStatus().Value
+		fields["priority"] = cfg.NewPriority().Weight
+		fields["tag"] = ""
+		for _, col := range []string{"due", "project", "estimate"} {
+			fields[col] = nil
+		}
+	}
+	if in.Text != nil {
+		fields["text"] = strings.TrimSpace(*in.Text)
+	}
+	if in.Status != nil {
+		if s, exists := cfg.Status(strings.ToLower(*in.Status)); exists {
+			fields["status"] = s.Value
+		} else {
+			problems = append(problems, fmt.Sprintf("Invalid status %v", *in.Status))
+		}
+	}
+	if in.Priority != nil {
+		if p, exists := cfg.Priority(*in.Priority); exists {
+			fields["priority"] = p.Weight
+		} else {
+			problems = append(problems, fmt.Sprintf("Invalid priority %v", *in.Priority))
+		}
+	}
+	if in.Due != nil {
+		value := strings.ToLower(strings.TrimSpace(*in.Due))
+		if _, err := time.ParseInLocation("2006-01-02 15:04", value, time.Local); err == nil {
+			fields["due"] = value
+		} else if value == "" {
+			fields["due"] = nil
+		} else {
+			d := &due{value: &value}
+			if msg := service.Check(d); len(msg) > 0 {
+				problems = append(problems, fmt.Sprintf("Invalid due date %v", *in.Due))
+			} else {
+				fields["due"] = *d.value
+			}
+		}
+	}
+	if in.Tags != nil {
+		fields["tag"] = ""
+		var tags []string
+		for _, tag := range *in.Tags {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+		if len(tags) > 0 {
+			fields["tag"] = strings.Join(tags, ",")
+		}
+	}
+	if in.Project != nil {
+		fields["project"] = nil
+		if project := strings.TrimSpace(*in.Project); project != "" {
+			fields["project"] = project
+		}
+	}
+	if in.Estimate != nil {
+		fields["estimate"] = nil
+		if *in.Estimate < 0 {
+			problems = append(problems, fmt.Sprintf("Invalid estimate %v", *in.Estimate))
+		} else if *in.Estimate > 0 {
+			fields["estimate"] = *in.Estimate
+		}
+	}
+	return fields, problems
+}
+
+// FilterOf reads a filter from query parameters named after the list
+// flags.
+func FilterOf(q url.Values) (*service.Filter, []string) {
+	op, priority := service.SplitOp(strings.ToLower(q.Get("priority")))
+	f := &service.Filter{
+		Status:     strings.ToLower(q.Get("status")),
+		Priority:   priority,
+		PriorityOp: op,
+		Tag:        q.Get("tag"),
+		Project:    q.Get("project"),
+		Find:       q.Get("find"),
+		Created:    q.Get("created"),
+	}
+	if due := strings.ToLower(q.Get("due")); due != "" {
+		f.Due = &due
+	}
+	var problems []string
+	if fits, err := service.ParseEstimate(q.Get("fits")); err != nil {
+		problems = append(problems, err.Error())
+	} else {
+		f.Fits = fits
+	}
+	if v := q.Get("archived"); v != "" {
+		archived, err := strconv.ParseBool(v)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Invalid archived %v", v))
+		}
+		f.Archived = archived
+	}
+	problems = append(problems, service.Check(f)...)
+	if !service.IsValidCreated(f) {
+		problems = append(problems, fmt.Sprintf("Invalid created date %v", f.Created))
+	}
+	return f, problems
+}
+
+// ErrTransition is returned when the workflow does not allow a status
+// change.
+var ErrTransition = errors.New("status change not allowed")
+
+// Change writes fields on t unless it moved on from version.
+func Change(conn *sql.DB, t Task, version int64, fields map[string]any, source string) error {
+	cfg := config.Get()
+	next, changesStatus := cfg.Status(fmt.Sprint(fields["status"]))
+	if changesStatus {
+		if from, exists := cfg.Status(t.Status); exists && !from.Allows(next) {
+			return fmt.Errorf("%w: cannot move from %v to %v", ErrTransition, from.Name, next.Name)
+		}
+	}
+	return db.UpdateVersion(conn, t.ID, version, fields, source)
+}
+
+// Note is a note on a task.
+type Note struct {
+	Text      string    `json:"text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Notes returns the notes of the task with id, oldest first.
+func Notes(conn *sql.DB, id int) ([]Note, error) {
+	rows, err := conn.Query("SELECT text, created_at FROM notes WHERE todo_id = ? ORDER BY created_at, id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var notes []Note
+	for rows.Next() {
+		var n Note
+		if err := rows.Scan(&n.Text, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+	return notes, rows.Err()
+}
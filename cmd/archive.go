@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+func (app *App) archive() {
+	fs := flag.NewFlagSet("archive", flag.ExitOnError)
+	parse := service.Parse(fs, "archive [id]...")
+	cmd := newFilter(parse)
+	if isValid := service.Validate(cmd); !isValid {
+		os.Exit(1)
+	}
+	if isValid := service.IsValidCreated(cmd); !isValid {
+		fmt.Fprintf(os.Stderr, "Invalid created date %v\n", cmd.Created)
+		os.Exit(1)
+	}
+	var ids []int
+	if len(parse.NonFlagArgs) > 0 {
+		ids = service.ValidateIds(parse.NonFlagArgs)
+	} else {
+		// archive finished tasks unless asked otherwise, as the daemon does
+		var statuses []int
+		if cmd.Status == "" {
+			statuses = config.Get().TerminalStatuses()
+		}
+		var err error
+		if ids, err = app.archivable(cmd, statuses); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if len(ids) == 0 {
+		fmt.Println("Nothing to archive")
+		return
+	}
+	archivedAt := time.Now().Format(db.TimeFormat)
+	if err := db.Update(app.db, ids, map[string]any{"archived_at": archivedAt}, db.SourceCLI); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Archived id:", ids)
+}
+
+// archivable returns the ids of the unarchived todos matching cmd, and
+// in one of statuses when there are any.
+func (app *App) archivable(cmd *service.Filter, statuses []int) ([]int, error) {
+	where, args := cmd.Where()
+	if len(statuses) > 0 {
+		where += " AND status IN (" + db.Placeholders(len(statuses)) + ")"
+		for _, v := range statuses {
+			args = append(args, v)
+		}
+	}
+	rows, err := app.db.Query("SELECT id FROM todos"+where+" AND archived_at IS NULL", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+func (app *App) backup() {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	fs.Parse(os.Args[2:])
+	if fs.NArg() > 1 {
+		fmt.Println("usage: todo backup [FILE|-]")
+		os.Exit(1)
+	}
+	if fs.Arg(0) == "-" {
+		a, err := db.Dump(app.db)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := db.WriteArchive(os.Stdout, a); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	path := fs.Arg(0)
+	if path == "" {
+		dir := config.Get().Backup.Directory()
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			log.Fatal(err)
+		}
+		path = filepath.Join(dir, "todos-"+time.Now().Format("2006-01-02T150405")+".json")
+	}
+	if err := db.BackupFile(app.db, path); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Backup written to", path)
+}
+
+func (app *App) restore() {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	merge := fs.Bool("merge", false, "Add what is missing and keep the rest, the default")
+	replace := fs.Bool("replace", false, "Replace everything with the backup")
+	fs.Parse(os.Args[2:])
+	if fs.NArg() != 1 || (*merge && *replace) {
+		fmt.Println("usage: todo restore [--merge|--replace] FILE|-")
+		os.Exit(1)
+	}
+	var r io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+	a, err := db.ReadArchive(r)
+	if err != nil {
+		log.Fatal(err)
+	}
+	mode := db.Merge
+	if *replace {
+		mode = db.Replace
+		// what is replaced can be restored in turn
+		dir := config.Get().Backup.Directory()
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			log.Fatal(err)
+		}
+		path := filepath.Join(dir, "todos-"+time.Now().Format("2006-01-02T150405")+"-before-restore.json")
+		if err := db.BackupFile(app.db, path); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Current data backed up to", path)
+	}
+	written, err := db.Restore(app.db, a, mode)
+	if err != nil {
+		log.Fatal(err)
+	}
+	tables := make([]string, 0, len(written))
+	for table := range written {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+	fmt.Printf("Restored backup of %s (%s):\n", a.Created.Local().Format("2006-01-02 15:04"), mode)
+	for _, table := range tables {
+		fmt.Printf("  %-20s %d rows\n", table, written[table])
+	}
+}
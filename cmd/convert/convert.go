@@ -0,0 +1,88 @@
+// Package convert reads and writes tasks in the formats of other tools.
+// Readers report what they could not map instead of failing the import.
+package convert
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/benpsk/todo/config"
+)
+
+// Task is a task independent of any format. Status and Priority are
+// names of configured ones.
+type Task struct {
+	ID        int    // 0 when not stored yet
+	UUID      string // "" when the format has none
+	Text      string
+	Status    string // "" for the default
+	Priority  string // "" for none
+	Due       string // 2006-01-02 or 2006-01-02 15:04
+	Tags      []string
+	Project   string
+	Created   time.Time // zero when unknown
+	Finished  time.Time // when a terminal status was reached, zero when unknown
+	Modified  time.Time // zero when unknown
+	Notes     []Note
+	Depends   []string // UUIDs of the tasks to finish first
+	Recur     string   // RRULE value, "" when it does not repeat
+	Reminders []Reminder
+}
+
+type Note struct {
+	Time time.Time
+	Text string
+}
+
+// Reminder is either at a time or a duration before the due date.
+type Reminder struct {
+	At     time.Time
+	Before time.Duration
+}
+
+// Problem is a line or entry that could not be mapped, or only partly.
+type Problem struct {
+	Line   int // line or entry number, 0 when the problem is not in the file
+	Text   string
+	Reason string
+}
+
+func (p Problem) String() string {
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s", p.Reason, p.Text)
+	}
+	return fmt.Sprintf("%d: %s: %s", p.Line, p.Reason, p.Text)
+}
+
+// Formats lists the supported formats.
+var Formats = []string{"todotxt", "taskwarrior", "ics", "markdown"}
+
+// rankedPriorities returns the priorities other than none, highest first.
+func rankedPriorities() []config.Priority {
+	var ranked []config.Priority
+	for _, p := range config.Get().Priorities {
+		if p.Weight > 0 {
+			ranked = append(ranked, p)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Weight > ranked[j].Weight })
+	return ranked
+}
+
+// terminal reports whether the status called name is terminal.
+func terminal(name string) bool {
+	s, exists := config.Get().Status(name)
+	return exists && s.Terminal
+}
+
+// defaultStatus returns the status a task gets when none is given, the
+// first configured one, or the first terminal one when it is finished.
+func defaultStatus(finished bool) string {
+	for _, s := range config.Get().Statuses {
+		if s.Terminal == finished {
+			return s.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,152 @@
+package convert
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var created = time.Date(2025, 8, 1, 9, 30, 0, 0, time.Local)
+
+// sample has a task per kind of field, with the default status, one that
+// only todo has, and the two terminal ones.
+var sample = []Task{
+	{
+		UUID: "0b6c1c7e-2f1a-4a43-9d36-1c1f3f0a0001", Text: "Buy milk", Status: "pending", Priority: "high",
+		Due: "2025-08-06", Tags: []string{"home", "shop"}, Project: "house",
+		Created: created, Modified: created.Add(time.Hour),
+	},
+	{
+		UUID: "0b6c1c7e-2f1a-4a43-9d36-1c1f3f0a0002", Text: "Ship release", Status: "review", Priority: "urgent",
+		Due: "2025-08-06 15:04", Project: "web", Created: created, Modified: created.Add(2 * time.Hour),
+		Notes:     []Note{{Time: created.Add(time.Minute), Text: "ask QA"}},
+		Depends:   []string{"0b6c1c7e-2f1a-4a43-9d36-1c1f3f0a0001"},
+		Reminders: []Reminder{{Before: time.Hour}, {At: created.Add(24 * time.Hour)}},
+	},
+	{
+		UUID: "0b6c1c7e-2f1a-4a43-9d36-1c1f3f0a0003", Text: "Pay bills", Status: "done", Priority: "low",
+		Created: created, Finished: created.Add(48 * time.Hour), Modified: created.Add(48 * time.Hour),
+		Recur: "FREQ=MONTHLY",
+	},
+	{UUID: "0b6c1c7e-2f1a-4a43-9d36-1c1f3f0a0004", Text: "Old idea", Status: "cancelled"},
+}
+
+// day is the date of t, as formats without times keep it.
+func day(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// normalize makes tasks comparable: times in UTC and empty lists nil.
+func normalize(tasks []Task) []Task {
+	out := make([]Task, len(tasks))
+	for i, t := range tasks {
+		t.Created, t.Finished, t.Modified = t.Created.UTC(), t.Finished.UTC(), t.Modified.UTC()
+		if len(t.Tags) == 0 {
+			t.Tags = nil
+		}
+		if len(t.Depends) == 0 {
+			t.Depends = nil
+		}
+		var notes []Note
+		for _, n := range t.Notes {
+			notes = append(notes, Note{Time: n.Time.UTC(), Text: n.Text})
+		}
+		t.Notes = notes
+		var reminders []Reminder
+		for _, r := range t.Reminders {
+			reminders = append(reminders, Reminder{At: r.At.UTC(), Before: r.Before})
+		}
+		t.Reminders = reminders
+		out[i] = t
+	}
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		format string
+		write  func(io.Writer, []Task) error
+		read   func(io.Reader) ([]Task, []Problem, error)
+		// keep is what of a task the format carries
+		keep func(Task) Task
+	}{
+		{
+			format: "todotxt",
+			write:  WriteTodoTxt,
+			read:   ReadTodoTxt,
+			keep: func(t Task) Task {
+				if t.Status == defaultStatus(false) {
+					t.Status = ""
+				}
+				// a finished task has a creation date only after its finish date
+				created := day(t.Created)
+				if terminal(t.Status) && t.Finished.IsZero() {
+					created = time.Time{}
+				}
+				return Task{Text: t.Text, Status: t.Status, Priority: t.Priority, Due: t.Due, Tags: t.Tags,
+					Project: t.Project, Created: created, Finished: day(t.Finished)}
+			},
+		},
+		{
+			format: "taskwarrior",
+			write:  WriteTaskwarrior,
+			read:   ReadTaskwarrior,
+			keep: func(t Task) Task {
+				t.Reminders, t.Recur = nil, ""
+				return t
+			},
+		},
+		{
+			format: "ics",
+			write:  func(w io.Writer, tasks []Task) error { return WriteICS(w, tasks, false) },
+			read:   ReadICS,
+			keep:   func(t Task) Task { return t },
+		},
+		{
+			format: "markdown",
+			write:  func(w io.Writer, tasks []Task) error { return WriteMarkdown(w, tasks, "none") },
+			read:   func(r io.Reader) ([]Task, []Problem, error) { return ReadMarkdown(r, "none") },
+			keep: func(t Task) Task {
+				if t.Status == defaultStatus(false) {
+					t.Status = ""
+				}
+				return Task{Text: t.Text, Status: t.Status, Priority: t.Priority, Due: t.Due, Tags: t.Tags,
+					Project: t.Project}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.write(&buf, sample); err != nil {
+				t.Fatal(err)
+			}
+			written := buf.String()
+			got, problems, err := tt.read(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(problems) > 0 {
+				t.Errorf("problems reading back: %v", problems)
+			}
+			var want []Task
+			for _, task := range sample {
+				want = append(want, tt.keep(task))
+			}
+			got, want = normalize(got), normalize(want)
+			if len(got) != len(want) {
+				t.Fatalf("read %d tasks, want %d\n%s", len(got), len(want), written)
+			}
+			for i := range want {
+				if !reflect.DeepEqual(got[i], want[i]) {
+					t.Errorf("task %d\n got %+v\nwant %+v\n%s", i, got[i], want[i], written)
+				}
+			}
+		})
+	}
+}
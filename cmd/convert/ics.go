@@ -0,0 +1,411 @@
+package convert
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benpsk/todo/cmd/ical"
+	"github.com/benpsk/todo/config"
+)
+
+// iCalendar, see https://www.rfc-editor.org/rfc/rfc5545. Tasks are
+// VTODOs with the UUID as UID, tags as CATEGORIES and reminders as
+// VALARMs. PRIORITY spreads the configured priorities over 1 (highest) to
+// 9, and STATUS is COMPLETED or CANCELLED for terminal statuses, else
+// NEEDS-ACTION or IN-PROCESS. The project and the exact status and
+// priority, when the standard values lose them, are kept in X-TODO-
+// properties, notes in COMMENTs and dependencies in RELATED-TO.
+
+// icsIgnored are properties that do not matter to a task.
+var icsIgnored = map[string]bool{
+	"DTSTAMP": true, "SEQUENCE": true, "CLASS": true, "PERCENT-COMPLETE": true,
+	"URL": true, "ORGANIZER": true, "ATTENDEE": true, "GEO": true, "LOCATION": true,
+}
+
+// ReadICS reads the VTODOs of a calendar. Other components are skipped.
+func ReadICS(r io.Reader) ([]Task, []Problem, error) {
+	root, err := ical.Parse(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	var tasks []Task
+	var problems []Problem
+	for n, todo := range root.All("VTODO") {
+		t, reasons := parseVTodo(todo)
+		for _, reason := range reasons {
+			problems = append(problems, Problem{Line: n + 1, Text: t.Text, Reason: reason})
+		}
+		if strings.TrimSpace(t.Text) == "" {
+			problems = append(problems, Problem{Line: n + 1, Text: t.UUID, Reason: "no summary, skipped"})
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	if events := len(root.All("VEVENT")); events > 0 {
+		problems = append(problems, Problem{Text: fmt.Sprintf("%d events", events), Reason: "skipped, only to-dos are imported"})
+	}
+	return tasks, problems, nil
+}
+
+func parseVTodo(c *ical.Component) (Task, []string) {
+	var t Task
+	var reasons []string
+	var start time.Time
+	var unmapped []string
+	for _, p := range c.Properties {
+		invalid := func(err error) {
+			reasons = append(reasons, fmt.Sprintf("invalid %s %q: %v", p.Name, p.Value, err))
+		}
+		switch p.Name {
+		case "UID":
+			t.UUID = p.Value
+		case "SUMMARY":
+			t.Text = strings.Join(strings.Fields(ical.Text(p.Value)), " ")
+		case "DESCRIPTION", "COMMENT":
+			n := Note{Text: ical.Text(p.Value)}
+			if at, exists := p.Params["X-TODO-TIME"]; exists {
+				n.Time, _, _ = ical.Property{Value: at, Params: map[string]string{}}.Time()
+			}
+			if strings.TrimSpace(n.Text) != "" {
+				t.Notes = append(t.Notes, n)
+			}
+		case "CATEGORIES":
+			for _, tag := range splitText(p.Value) {
+				if tag = strings.TrimSpace(tag); tag != "" {
+					t.Tags = append(t.Tags, tag)
+				}
+			}
+		case "DUE":
+			due, allDay, err := p.Time()
+			if err != nil {
+				invalid(err)
+			} else if allDay {
+				t.Due = due.Format(dateLayout)
+			} else {
+				t.Due = due.Format(dateLayout + " 15:04")
+			}
+		case "DTSTART":
+			start, _, _ = p.Time()
+		case "CREATED", "LAST-MODIFIED", "COMPLETED":
+			at, _, err := p.Time()
+			if err != nil {
+				invalid(err)
+				continue
+			}
+			switch p.Name {
+			case "CREATED":
+				t.Created = at
+			case "LAST-MODIFIED":
+				t.Modified = at
+			default:
+				t.Finished = at
+			}
+		case "RRULE":
+			// kept to be exported again, todo does not repeat tasks
+			t.Recur = p.Value
+		case "RELATED-TO":
+			if strings.ToUpper(p.Params["RELTYPE"]) == "DEPENDS-ON" {
+				t.Depends = append(t.Depends, p.Value)
+			} else {
+				unmapped = append(unmapped, "RELATED-TO")
+			}
+		case "X-TODO-PROJECT":
+			t.Project = ical.Text(p.Value)
+		case "STATUS", "PRIORITY", "X-TODO-STATUS", "X-TODO-PRIORITY":
+			// below, the X-TODO- ones win
+		default:
+			if !icsIgnored[p.Name] && !strings.HasPrefix(p.Name, "X-") {
+				unmapped = append(unmapped, p.Name)
+			}
+		}
+	}
+	if len(unmapped) > 0 {
+		sort.Strings(unmapped)
+		reasons = append(reasons, "not mapped: "+strings.Join(unmapped, ", "))
+	}
+
+	status, reason := statusOfICS(c.Value("STATUS"), c.Value("X-TODO-STATUS"))
+	if reason != "" {
+		reasons = append(reasons, reason)
+	}
+	t.Status = status
+	if name := c.Value("X-TODO-PRIORITY"); name != "" {
+		if p, exists := config.Get().Priority(name); exists {
+			t.Priority = p.Name
+		} else {
+			reasons = append(reasons, fmt.Sprintf("unknown X-TODO-PRIORITY %q", name))
+		}
+	}
+	if v := c.Value("PRIORITY"); t.Priority == "" && v != "" && v != "0" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > 9 {
+			reasons = append(reasons, fmt.Sprintf("invalid PRIORITY %q", v))
+		} else {
+			t.Priority = priorityOfICS(n)
+		}
+	}
+	for _, alarm := range c.All("VALARM") {
+		r, reason := parseAlarm(alarm, start)
+		if reason != "" {
+			reasons = append(reasons, reason)
+			continue
+		}
+		t.Reminders = append(t.Reminders, r)
+	}
+	for i := range t.Notes {
+		if t.Notes[i].Time.IsZero() {
+			t.Notes[i].Time = t.Created
+		}
+	}
+	return t, reasons
+}
+
+// parseAlarm reads the TRIGGER of a VALARM, an absolute time or a
+// duration before the due date. start is the DTSTART of the to-do, zero
+// when it has none.
+func parseAlarm(alarm *ical.Component, start time.Time) (Reminder, string) {
+	trigger, exists := alarm.Get("TRIGGER")
+	if !exists {
+		return Reminder{}, "alarm without TRIGGER dropped"
+	}
+	if trigger.Params["VALUE"] == "DATE-TIME" {
+		at, _, err := trigger.Time()
+		if err != nil {
+			return Reminder{}, fmt.Sprintf("invalid TRIGGER %q", trigger.Value)
+		}
+		return Reminder{At: at}, ""
+	}
+	d, err := ical.ParseDuration(trigger.Value)
+	if err != nil {
+		return Reminder{}, fmt.Sprintf("invalid TRIGGER %q", trigger.Value)
+	}
+	if strings.ToUpper(trigger.Params["RELATED"]) != "END" && !start.IsZero() {
+		return Reminder{At: start.Add(d)}, ""
+	}
+	if d > 0 {
+		return Reminder{}, fmt.Sprintf("alarm %s after due dropped", trigger.Value)
+	}
+	return Reminder{Before: -d}, ""
+}
+
+// splitText splits a comma separated TEXT list, keeping escaped commas.
+func splitText(v string) []string {
+	var list []string
+	var item strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch {
+		case v[i] == '\\' && i+1 < len(v):
+			item.WriteByte(v[i])
+			item.WriteByte(v[i+1])
+			i++
+		case v[i] == ',':
+			list = append(list, ical.Text(item.String()))
+			item.Reset()
+		default:
+			item.WriteByte(v[i])
+		}
+	}
+	return append(list, ical.Text(item.String()))
+}
+
+// icsInProcess are status names taken for IN-PROCESS, in order.
+var icsInProcess = []string{"in-process", "in-progress", "processing", "doing"}
+
+// statusOfICS maps a STATUS. The X-TODO-STATUS wins when it names a
+// configured status.
+func statusOfICS(status, x string) (string, string) {
+	cfg := config.Get()
+	if x != "" {
+		if s, exists := cfg.Status(x); exists {
+			return s.Name, ""
+		}
+	}
+	var reason string
+	if x != "" {
+		reason = fmt.Sprintf("unknown X-TODO-STATUS %q", x)
+	}
+	switch strings.ToUpper(status) {
+	case "COMPLETED":
+		return defaultStatus(true), reason
+	case "CANCELLED":
+		if s, exists := cfg.Status("cancelled"); exists && s.Terminal {
+			return s.Name, reason
+		}
+		return defaultStatus(true), reason
+	case "IN-PROCESS":
+		for _, name := range icsInProcess {
+			if s, exists := cfg.Status(name); exists && !s.Terminal {
+				return s.Name, reason
+			}
+		}
+	case "NEEDS-ACTION", "":
+	default:
+		reason = fmt.Sprintf("unknown STATUS %q, imported as %s", status, defaultStatus(false))
+	}
+	return defaultStatus(false), reason
+}
+
+// icsStatus is the STATUS of a task.
+func icsStatus(name string) string {
+	switch {
+	case name == defaultStatus(true):
+		return "COMPLETED"
+	case terminal(name):
+		return "CANCELLED"
+	case name == "" || name == defaultStatus(false):
+		return "NEEDS-ACTION"
+	}
+	return "IN-PROCESS"
+}
+
+// priorityOfICS maps 1 (highest) to 9 onto the ranked priorities.
+func priorityOfICS(n int) string {
+	ranked := rankedPriorities()
+	if len(ranked) == 0 {
+		return ""
+	}
+	i := int(math.Round(float64(n-1) * float64(len(ranked)-1) / 8))
+	return ranked[i].Name
+}
+
+// icsPriority is the PRIORITY of a priority, 0 for none.
+func icsPriority(name string) int {
+	ranked := rankedPriorities()
+	for i, p := range ranked {
+		if p.Name != name {
+			continue
+		}
+		if len(ranked) == 1 {
+			return 5
+		}
+		return 1 + int(math.Round(float64(i)*8/float64(len(ranked)-1)))
+	}
+	return 0
+}
+
+// WriteICS writes a calendar with a VTODO per task. With asEvents, tasks
+// due at a time are VEVENTs starting then instead, so they show in any
+// calendar app, with an alarm at the start when they have no reminders.
+func WriteICS(w io.Writer, tasks []Task, asEvents bool) error {
+	cal := &ical.Component{Name: "VCALENDAR"}
+	cal.Add("VERSION", "2.0")
+	cal.Add("PRODID", "-//benpsk//todo//EN")
+	now := time.Now()
+	for _, t := range tasks {
+		due, timed, hasDue := parseDue(t.Due)
+		event := asEvents && hasDue && timed
+		c := &ical.Component{Name: "VTODO"}
+		if event {
+			c.Name = "VEVENT"
+		}
+		c.Add("UID", t.UUID)
+		stamp := now
+		if !t.Modified.IsZero() {
+			stamp = t.Modified
+		}
+		c.Add("DTSTAMP", ical.DateTime(stamp))
+		c.Add("SUMMARY", ical.Escape(t.Text))
+		switch {
+		case event:
+			c.Add("DTSTART", ical.DateTime(due))
+		case hasDue && timed:
+			c.Add("DUE", ical.DateTime(due))
+		case hasDue:
+			c.Properties = append(c.Properties, ical.Property{Name: "DUE", Params: map[string]string{"VALUE": "DATE"}, Value: ical.Date(due)})
+		}
+		if !event {
+			status := icsStatus(t.Status)
+			c.Add("STATUS", status)
+			if s, _ := statusOfICS(status, ""); t.Status != "" && s != t.Status {
+				c.Add("X-TODO-STATUS", t.Status)
+			}
+		}
+		if n := icsPriority(t.Priority); n > 0 {
+			c.Add("PRIORITY", strconv.Itoa(n))
+			if priorityOfICS(n) != t.Priority {
+				c.Add("X-TODO-PRIORITY", t.Priority)
+			}
+		}
+		if len(t.Tags) > 0 {
+			tags := make([]string, len(t.Tags))
+			for i, tag := range t.Tags {
+				tags[i] = ical.Escape(tag)
+			}
+			c.Add("CATEGORIES", strings.Join(tags, ","))
+		}
+		if t.Project != "" {
+			c.Add("X-TODO-PROJECT", ical.Escape(t.Project))
+		}
+		if t.Recur != "" {
+			c.Add("RRULE", t.Recur)
+		}
+		if !t.Created.IsZero() {
+			c.Add("CREATED", ical.DateTime(t.Created))
+		}
+		if !t.Modified.IsZero() {
+			c.Add("LAST-MODIFIED", ical.DateTime(t.Modified))
+		}
+		if !t.Finished.IsZero() && !event && icsStatus(t.Status) == "COMPLETED" {
+			c.Add("COMPLETED", ical.DateTime(t.Finished))
+		}
+		for _, n := range t.Notes {
+			c.Properties = append(c.Properties, ical.Property{
+				Name:   "COMMENT",
+				Params: map[string]string{"X-TODO-TIME": ical.DateTime(n.Time)},
+				Value:  ical.Escape(n.Text),
+			})
+		}
+		for _, uuid := range t.Depends {
+			c.Properties = append(c.Properties, ical.Property{Name: "RELATED-TO", Params: map[string]string{"RELTYPE": "DEPENDS-ON"}, Value: uuid})
+		}
+		for _, r := range t.Reminders {
+			if r.At.IsZero() && !hasDue {
+				continue
+			}
+			c.Children = append(c.Children, alarm(t.Text, r, event))
+		}
+		if event && len(t.Reminders) == 0 {
+			c.Children = append(c.Children, alarm(t.Text, Reminder{}, event))
+		}
+		cal.Children = append(cal.Children, c)
+	}
+	return ical.Write(w, cal)
+}
+
+// alarm is the VALARM of a reminder, relative to the start of an event
+// or to the due date of a to-do.
+func alarm(text string, r Reminder, event bool) *ical.Component {
+	a := &ical.Component{Name: "VALARM"}
+	a.Add("ACTION", "DISPLAY")
+	a.Add("DESCRIPTION", ical.Escape(text))
+	trigger := ical.Property{Name: "TRIGGER", Params: map[string]string{}}
+	switch {
+	case !r.At.IsZero():
+		trigger.Params["VALUE"] = "DATE-TIME"
+		trigger.Value = ical.DateTime(r.At)
+	case event:
+		trigger.Value = ical.FormatDuration(-r.Before)
+	default:
+		trigger.Params["RELATED"] = "END"
+		trigger.Value = ical.FormatDuration(-r.Before)
+	}
+	a.Properties = append(a.Properties, trigger)
+	return a
+}
+
+// parseDue parses a Task due date, timed is false for a date only.
+func parseDue(due string) (t time.Time, timed bool, ok bool) {
+	if due == "" {
+		return time.Time{}, false, false
+	}
+	if t, err := time.ParseInLocation(dateLayout+" 15:04", due, time.Local); err == nil {
+		return t, true, true
+	}
+	t, err := time.ParseInLocation(dateLayout, due, time.Local)
+	return t, false, err == nil
+}
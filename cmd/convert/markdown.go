@@ -0,0 +1,248 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/benpsk/todo/config"
+)
+
+// Markdown checklists, as in GitHub flavored markdown. "- [ ]" and
+// "- [x]" items are tasks, headings above them are their project or tag,
+// #tag words are tags and @due(2006-01-02 15:04), @priority(high),
+// @status(review) and @project(web) set the rest. #123 stays text, it is
+// usually an issue. Other lines are not tasks and are left alone.
+
+// MarkdownHeadings lists what headings can be read as.
+var MarkdownHeadings = []string{"project", "tag", "none"}
+
+// MarkdownGroups lists what an export can be grouped by.
+var MarkdownGroups = []string{"none", "tag", "project", "status", "priority"}
+
+var (
+	mdHeading = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdItem    = regexp.MustCompile(`^\s*(?:[-*+]|\d+[.)])\s+\[([ xX])\]\s+(.*)$`)
+	mdMarker  = regexp.MustCompile(`@(due|priority|status|project)\(([^)]*)\)`)
+	mdTag     = regexp.MustCompile(`^#[\p{L}_][\p{L}\p{N}_./-]*$`)
+)
+
+// ReadMarkdown reads the checklist items of a markdown file. headings is
+// what the closest heading above an item is read as, one of
+// MarkdownHeadings.
+func ReadMarkdown(r io.Reader, headings string) ([]Task, []Problem, error) {
+	var tasks []Task
+	var problems []Problem
+	var levels [7]string // heading text per level, "" when none
+	heading := ""
+	fenced := false
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			fenced = !fenced
+			continue
+		}
+		if fenced {
+			continue
+		}
+		if m := mdHeading.FindStringSubmatch(line); m != nil {
+			level := len(m[1])
+			levels[level] = m[2]
+			for i := level + 1; i < len(levels); i++ {
+				levels[i] = ""
+			}
+			heading = ""
+			for i := len(levels) - 1; i > 0 && heading == ""; i-- {
+				heading = levels[i]
+			}
+			continue
+		}
+		m := mdItem.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		t, reasons := parseMarkdownItem(m[2], m[1] != " ")
+		for _, reason := range reasons {
+			problems = append(problems, Problem{Line: n, Text: strings.TrimSpace(line), Reason: reason})
+		}
+		if t.Text == "" {
+			problems = append(problems, Problem{Line: n, Text: strings.TrimSpace(line), Reason: "no task text, skipped"})
+			continue
+		}
+		if heading != "" {
+			switch headings {
+			case "project":
+				if t.Project == "" {
+					t.Project = heading
+				}
+			case "tag":
+				t.Tags = append([]string{heading}, t.Tags...)
+			}
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, problems, scanner.Err()
+}
+
+func parseMarkdownItem(item string, done bool) (Task, []string) {
+	var t Task
+	var reasons []string
+	item = mdMarker.ReplaceAllStringFunc(item, func(marker string) string {
+		m := mdMarker.FindStringSubmatch(marker)
+		value := strings.TrimSpace(m[2])
+		switch m[1] {
+		case "due":
+			due, err := parseTodoTxtDue(strings.Replace(value, " ", "T", 1))
+			if err != nil {
+				reasons = append(reasons, fmt.Sprintf("invalid due date %q kept in text", value))
+				return marker
+			}
+			t.Due = due
+		case "priority":
+			p, exists := config.Get().Priority(value)
+			if !exists {
+				reasons = append(reasons, fmt.Sprintf("unknown priority %q kept in text", value))
+				return marker
+			}
+			if p.Weight > 0 {
+				t.Priority = p.Name
+			}
+		case "status":
+			s, exists := config.Get().Status(value)
+			if !exists {
+				reasons = append(reasons, fmt.Sprintf("unknown status %q kept in text", value))
+				return marker
+			}
+			t.Status = s.Name
+		case "project":
+			t.Project = value
+		}
+		return ""
+	})
+	var text []string
+	for _, w := range strings.Fields(item) {
+		if mdTag.MatchString(w) {
+			t.Tags = append(t.Tags, w[1:])
+			continue
+		}
+		text = append(text, w)
+	}
+	t.Text = strings.Join(text, " ")
+	if t.Status == "" && done {
+		t.Status = defaultStatus(true)
+	}
+	if t.Status != "" && done != terminal(t.Status) {
+		reasons = append(reasons, fmt.Sprintf("status %s does not match the checkbox, status kept", t.Status))
+	}
+	return t, reasons
+}
+
+// WriteMarkdown writes a checklist, under a heading per group unless
+// groupBy is "none". Tasks without a value for the group come first.
+func WriteMarkdown(w io.Writer, tasks []Task, groupBy string) error {
+	bw := bufio.NewWriter(w)
+	groups := map[string][]Task{}
+	for _, t := range tasks {
+		key := groupOf(t, groupBy)
+		groups[key] = append(groups[key], t)
+	}
+	first := true
+	for _, key := range groupOrder(groups, groupBy) {
+		if !first {
+			bw.WriteString("\n")
+		}
+		first = false
+		if key != "" {
+			fmt.Fprintf(bw, "## %s\n\n", key)
+		}
+		for _, t := range groups[key] {
+			fmt.Fprintln(bw, markdownItem(t, groupBy))
+		}
+	}
+	return bw.Flush()
+}
+
+// groupOf is the heading of a task, its first tag when grouped by tag.
+func groupOf(t Task, groupBy string) string {
+	switch groupBy {
+	case "tag":
+		if len(t.Tags) > 0 {
+			return t.Tags[0]
+		}
+	case "project":
+		return t.Project
+	case "status":
+		if t.Status == "" {
+			return defaultStatus(false)
+		}
+		return t.Status
+	case "priority":
+		return t.Priority
+	}
+	return ""
+}
+
+// groupOrder sorts tags and projects by name, statuses as configured and
+// priorities from the highest.
+func groupOrder(groups map[string][]Task, groupBy string) []string {
+	var order []string
+	if _, exists := groups[""]; exists {
+		order = append(order, "")
+	}
+	var names []string
+	switch groupBy {
+	case "status":
+		for _, s := range config.Get().Statuses {
+			names = append(names, s.Name)
+		}
+	case "priority":
+		for _, p := range rankedPriorities() {
+			names = append(names, p.Name)
+		}
+	default:
+		for key := range groups {
+			names = append(names, key)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		if _, exists := groups[name]; exists && name != "" {
+			order = append(order, name)
+		}
+	}
+	return order
+}
+
+func markdownItem(t Task, groupBy string) string {
+	done := terminal(t.Status)
+	box := "[ ]"
+	if done {
+		box = "[x]"
+	}
+	words := []string{"-", box, t.Text}
+	if t.Due != "" {
+		words = append(words, "@due("+t.Due+")")
+	}
+	// status and priority headings are not read back, so they stay inline
+	if t.Priority != "" {
+		words = append(words, "@priority("+t.Priority+")")
+	}
+	if t.Status != "" && t.Status != defaultStatus(done) {
+		words = append(words, "@status("+t.Status+")")
+	}
+	if t.Project != "" && groupBy != "project" {
+		words = append(words, "@project("+t.Project+")")
+	}
+	tags := t.Tags
+	if groupBy == "tag" && len(tags) > 0 {
+		tags = tags[1:]
+	}
+	for _, tag := range tags {
+		words = append(words, "#"+word(tag))
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,331 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/benpsk/todo/config"
+)
+
+// Taskwarrior's "task export" JSON, see
+// https://taskwarrior.org/docs/design/task/. Statuses and priorities
+// Taskwarrior has no value for are kept in the todo_status and
+// todo_priority UDAs, so an export imports back unchanged.
+
+const twTime = "20060102T150405Z"
+
+type twTask struct {
+	UUID        string         `json:"uuid"`
+	Description string         `json:"description"`
+	Status      string         `json:"status"`
+	Priority    string         `json:"priority,omitempty"`
+	Due         string         `json:"due,omitempty"`
+	Entry       string         `json:"entry,omitempty"`
+	Modified    string         `json:"modified,omitempty"`
+	End         string         `json:"end,omitempty"`
+	Tags        []string       `json:"tags,omitempty"`
+	Project     string         `json:"project,omitempty"`
+	Annotations []twAnnotation `json:"annotations,omitempty"`
+	Depends     twDepends      `json:"depends,omitempty"`
+
+	TodoStatus   string `json:"todo_status,omitempty"`
+	TodoPriority string `json:"todo_priority,omitempty"`
+}
+
+type twAnnotation struct {
+	Entry       string `json:"entry"`
+	Description string `json:"description"`
+}
+
+// twDepends is a list of UUIDs, written by Taskwarrior before 2.6 as one
+// comma separated string.
+type twDepends []string
+
+func (d *twDepends) UnmarshalJSON(data []byte) error {
+	var list []string
+	if err := json.Unmarshal(data, &list); err == nil {
+		*d = list
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*d = nil
+	for _, uuid := range strings.Split(s, ",") {
+		if uuid = strings.TrimSpace(uuid); uuid != "" {
+			*d = append(*d, uuid)
+		}
+	}
+	return nil
+}
+
+// twIgnored are computed by Taskwarrior and not worth reporting.
+var twIgnored = map[string]bool{"id": true, "urgency": true}
+
+// ReadTaskwarrior reads a JSON array of tasks, or one task per line as
+// "task import" also accepts.
+func ReadTaskwarrior(r io.Reader) ([]Task, []Problem, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	var raws []json.RawMessage
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &raws); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+				raws = append(raws, json.RawMessage(bytes.Clone(line)))
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var tasks []Task
+	var problems []Problem
+	for i, raw := range raws {
+		n := i + 1
+		var tw twTask
+		if err := json.Unmarshal(raw, &tw); err != nil {
+			problems = append(problems, Problem{Line: n, Text: string(raw), Reason: err.Error()})
+			continue
+		}
+		problem := func(reason string) {
+			problems = append(problems, Problem{Line: n, Text: tw.Description, Reason: reason})
+		}
+		if tw.Status == "recurring" {
+			problem("recurring template skipped, its instances are imported")
+			continue
+		}
+		if strings.TrimSpace(tw.Description) == "" {
+			problem("no description, skipped")
+			continue
+		}
+		var fields map[string]json.RawMessage
+		json.Unmarshal(raw, &fields)
+		var unmapped []string
+		for key := range fields {
+			if !twIgnored[key] && !twMapped(key) {
+				unmapped = append(unmapped, key)
+			}
+		}
+		if len(unmapped) > 0 {
+			sort.Strings(unmapped)
+			problem("not mapped: " + strings.Join(unmapped, ", "))
+		}
+
+		t := Task{
+			UUID:    tw.UUID,
+			Text:    tw.Description,
+			Tags:    tw.Tags,
+			Project: tw.Project,
+			Depends: tw.Depends,
+		}
+		status, reason := statusOfTW(tw.Status, tw.TodoStatus)
+		if reason != "" {
+			problem(reason)
+		}
+		t.Status = status
+		if tw.TodoPriority != "" {
+			if p, exists := config.Get().Priority(tw.TodoPriority); exists {
+				t.Priority = p.Name
+			} else {
+				problem(fmt.Sprintf("unknown todo_priority %q", tw.TodoPriority))
+			}
+		}
+		if t.Priority == "" && tw.Priority != "" {
+			if t.Priority = priorityOfTW(tw.Priority); t.Priority == "" {
+				problem(fmt.Sprintf("unknown priority %q", tw.Priority))
+			}
+		}
+		for _, d := range []struct {
+			value string
+			name  string
+			to    *time.Time
+		}{{tw.Entry, "entry", &t.Created}, {tw.Modified, "modified", &t.Modified}, {tw.End, "end", &t.Finished}} {
+			if d.value == "" {
+				continue
+			}
+			at, err := time.Parse(twTime, d.value)
+			if err != nil {
+				problem(fmt.Sprintf("invalid %s %q", d.name, d.value))
+				continue
+			}
+			*d.to = at.Local()
+		}
+		if tw.Due != "" {
+			due, err := time.Parse(twTime, tw.Due)
+			if err != nil {
+				problem(fmt.Sprintf("invalid due %q", tw.Due))
+			} else if due = due.Local(); due.Hour() == 0 && due.Minute() == 0 && due.Second() == 0 {
+				t.Due = due.Format(dateLayout)
+			} else {
+				t.Due = due.Format(dateLayout + " 15:04")
+			}
+		}
+		for _, a := range tw.Annotations {
+			at, _ := time.Parse(twTime, a.Entry)
+			t.Notes = append(t.Notes, Note{Time: at.Local(), Text: a.Description})
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, problems, nil
+}
+
+func twMapped(key string) bool {
+	switch key {
+	case "uuid", "description", "status", "priority", "due", "entry", "modified", "end",
+		"tags", "project", "annotations", "depends", "todo_status", "todo_priority":
+		return true
+	}
+	return false
+}
+
+// statusOfTW maps a Taskwarrior status. The todo_status UDA wins when it
+// names a configured status.
+func statusOfTW(status, uda string) (string, string) {
+	if uda != "" {
+		if s, exists := config.Get().Status(uda); exists {
+			return s.Name, ""
+		}
+	}
+	var reason string
+	if uda != "" {
+		reason = fmt.Sprintf("unknown todo_status %q", uda)
+	}
+	switch status {
+	case "completed":
+		return defaultStatus(true), reason
+	case "deleted":
+		if s, exists := config.Get().Status("cancelled"); exists && s.Terminal {
+			return s.Name, reason
+		}
+		return defaultStatus(true), reason
+	case "waiting":
+		if s, exists := config.Get().Status("waiting"); exists && !s.Terminal {
+			return s.Name, reason
+		}
+	case "pending", "":
+	default:
+		reason = fmt.Sprintf("unknown status %q, imported as %s", status, defaultStatus(false))
+	}
+	return defaultStatus(false), reason
+}
+
+// twStatus is the Taskwarrior status of a task. Waiting needs a wait date
+// in Taskwarrior, so every open status is pending.
+func twStatus(name string) string {
+	if !terminal(name) {
+		return "pending"
+	}
+	if name == defaultStatus(true) {
+		return "completed"
+	}
+	return "deleted"
+}
+
+// twPriorities are Taskwarrior's priorities, highest first.
+var twPriorities = []string{"H", "M", "L"}
+
+// priorityOfTW maps H, M and L to the configured priority with that
+// alias, or else by rank.
+func priorityOfTW(letter string) string {
+	if p, exists := config.Get().Priority(strings.ToLower(letter)); exists {
+		return p.Name
+	}
+	ranked := rankedPriorities()
+	if len(ranked) == 0 {
+		return ""
+	}
+	switch letter {
+	case "H":
+		return ranked[0].Name
+	case "M":
+		return ranked[len(ranked)/2].Name
+	case "L":
+		return ranked[len(ranked)-1].Name
+	}
+	return ""
+}
+
+// twPriority is the Taskwarrior priority at or below a priority.
+func twPriority(name string) string {
+	p, exists := config.Get().Priority(name)
+	if !exists || p.Weight <= 0 {
+		return ""
+	}
+	for _, letter := range twPriorities {
+		if q, exists := config.Get().Priority(priorityOfTW(letter)); exists && q.Weight <= p.Weight {
+			return letter
+		}
+	}
+	return ""
+}
+
+// WriteTaskwarrior writes a JSON array with a task per line, like
+// "task export".
+func WriteTaskwarrior(w io.Writer, tasks []Task) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString("[\n")
+	for i, t := range tasks {
+		tw := twTask{
+			UUID:        t.UUID,
+			Description: t.Text,
+			Status:      twStatus(t.Status),
+			Priority:    twPriority(t.Priority),
+			Tags:        t.Tags,
+			Project:     t.Project,
+			Depends:     t.Depends,
+		}
+		if s, _ := statusOfTW(tw.Status, ""); s != t.Status {
+			tw.TodoStatus = t.Status
+		}
+		if t.Priority != "" && priorityOfTW(tw.Priority) != t.Priority {
+			tw.TodoPriority = t.Priority
+		}
+		if t.Due != "" {
+			due, err := time.ParseInLocation(dateLayout+" 15:04", t.Due, time.Local)
+			if err != nil {
+				due, err = time.ParseInLocation(dateLayout, t.Due, time.Local)
+			}
+			if err == nil {
+				tw.Due = due.UTC().Format(twTime)
+			}
+		}
+		for _, d := range []struct {
+			at time.Time
+			to *string
+		}{{t.Created, &tw.Entry}, {t.Modified, &tw.Modified}, {t.Finished, &tw.End}} {
+			if !d.at.IsZero() {
+				*d.to = d.at.UTC().Format(twTime)
+			}
+		}
+		for _, n := range t.Notes {
+			tw.Annotations = append(tw.Annotations, twAnnotation{Entry: n.Time.UTC().Format(twTime), Description: n.Text})
+		}
+		data, err := json.Marshal(tw)
+		if err != nil {
+			return err
+		}
+		bw.Write(data)
+		if i < len(tasks)-1 {
+			bw.WriteString(",")
+		}
+		bw.WriteString("\n")
+	}
+	bw.WriteString("]\n")
+	return bw.Flush()
+}
@@ -0,0 +1,220 @@
+package convert
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/benpsk/todo/config"
+)
+
+// todo.txt, see https://github.com/todotxt/todo.txt. Priorities (A) to
+// the number of configured priorities map highest first, +project to the
+// project, @context to tags and "x" to done. Times are kept with
+// due:2006-01-02T15:04, statuses other than the default with status:name
+// and the priority of finished tasks with pri:A. Only the last +project
+// is the project, other ones and other key:value pairs stay in the text.
+
+const dateLayout = "2006-01-02"
+
+// ReadTodoTxt reads one task per line.
+func ReadTodoTxt(r io.Reader) ([]Task, []Problem, error) {
+	var tasks []Task
+	var problems []Problem
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		t, reasons := parseTodoTxt(line)
+		for _, reason := range reasons {
+			problems = append(problems, Problem{Line: n, Text: line, Reason: reason})
+		}
+		if t.Text == "" {
+			problems = append(problems, Problem{Line: n, Text: line, Reason: "no task text, skipped"})
+			continue
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, problems, scanner.Err()
+}
+
+func parseTodoTxt(line string) (Task, []string) {
+	var t Task
+	var reasons []string
+	words := strings.Fields(line)
+	date := func() (time.Time, bool) {
+		if len(words) == 0 {
+			return time.Time{}, false
+		}
+		d, err := time.ParseInLocation(dateLayout, words[0], time.Local)
+		if err != nil {
+			return time.Time{}, false
+		}
+		words = words[1:]
+		return d, true
+	}
+
+	done := false
+	if words[0] == "x" {
+		done = true
+		words = words[1:]
+		if d, ok := date(); ok {
+			t.Finished = d
+			if d, ok := date(); ok {
+				t.Created = d
+			}
+		}
+	} else {
+		if w := words[0]; len(w) == 3 && w[0] == '(' && w[2] == ')' && w[1] >= 'A' && w[1] <= 'Z' {
+			name, reason := priorityOfLetter(w[1])
+			t.Priority = name
+			if reason != "" {
+				reasons = append(reasons, reason)
+			}
+			words = words[1:]
+		}
+		if d, ok := date(); ok {
+			t.Created = d
+		}
+	}
+
+	// the last +project is the project, the one written after the text
+	project := -1
+	for i, w := range words {
+		if len(w) > 1 && w[0] == '+' {
+			project = i
+		}
+	}
+	var text []string
+	for i, w := range words {
+		switch {
+		case i == project:
+			t.Project = w[1:]
+		case len(w) > 1 && w[0] == '@':
+			t.Tags = append(t.Tags, w[1:])
+		case strings.HasPrefix(w, "due:") && len(w) > 4:
+			due, err := parseTodoTxtDue(w[4:])
+			if err != nil {
+				reasons = append(reasons, fmt.Sprintf("invalid due date %q kept in text", w[4:]))
+				text = append(text, w)
+				continue
+			}
+			t.Due = due
+		case strings.HasPrefix(w, "status:") && len(w) > 7:
+			s, exists := config.Get().Status(w[7:])
+			if !exists {
+				reasons = append(reasons, fmt.Sprintf("unknown status %q kept in text", w[7:]))
+				text = append(text, w)
+				continue
+			}
+			t.Status = s.Name
+		case strings.HasPrefix(w, "pri:") && len(w) == 5 && w[4] >= 'A' && w[4] <= 'Z':
+			name, reason := priorityOfLetter(w[4])
+			t.Priority = name
+			if reason != "" {
+				reasons = append(reasons, reason)
+			}
+		default:
+			text = append(text, w)
+		}
+	}
+	t.Text = strings.Join(text, " ")
+	if t.Status == "" && done {
+		t.Status = defaultStatus(true)
+	}
+	if t.Status != "" && done != terminal(t.Status) {
+		reasons = append(reasons, fmt.Sprintf("status %s does not match the completion marker, status kept", t.Status))
+	}
+	return t, reasons
+}
+
+// parseTodoTxtDue accepts 2006-01-02 and 2006-01-02T15:04.
+func parseTodoTxtDue(v string) (string, error) {
+	if d, err := time.Parse(dateLayout+"T15:04", v); err == nil {
+		return d.Format(dateLayout + " 15:04"), nil
+	}
+	d, err := time.Parse(dateLayout, v)
+	if err != nil {
+		return "", err
+	}
+	return d.Format(dateLayout), nil
+}
+
+// priorityOfLetter maps A to the highest priority. Letters past the
+// lowest map to it, with a reason to report.
+func priorityOfLetter(letter byte) (string, string) {
+	ranked := rankedPriorities()
+	if len(ranked) == 0 {
+		return "", fmt.Sprintf("priority (%c) dropped, no priorities configured", letter)
+	}
+	i := int(letter - 'A')
+	if i >= len(ranked) {
+		lowest := ranked[len(ranked)-1].Name
+		return lowest, fmt.Sprintf("priority (%c) mapped to %s", letter, lowest)
+	}
+	return ranked[i].Name, ""
+}
+
+func letterOfPriority(name string) (byte, bool) {
+	for i, p := range rankedPriorities() {
+		if p.Name == name && i < 26 {
+			return byte('A' + i), true
+		}
+	}
+	return 0, false
+}
+
+// WriteTodoTxt writes one line per task.
+func WriteTodoTxt(w io.Writer, tasks []Task) error {
+	bw := bufio.NewWriter(w)
+	for _, t := range tasks {
+		var words []string
+		letter, hasPriority := letterOfPriority(t.Priority)
+		done := terminal(t.Status)
+		if done {
+			words = append(words, "x")
+			if !t.Finished.IsZero() {
+				words = append(words, t.Finished.Format(dateLayout))
+				if !t.Created.IsZero() {
+					words = append(words, t.Created.Format(dateLayout))
+				}
+			}
+		} else {
+			if hasPriority {
+				words = append(words, fmt.Sprintf("(%c)", letter))
+			}
+			if !t.Created.IsZero() {
+				words = append(words, t.Created.Format(dateLayout))
+			}
+		}
+		words = append(words, strings.Fields(t.Text)...)
+		if t.Project != "" {
+			words = append(words, "+"+word(t.Project))
+		}
+		for _, tag := range t.Tags {
+			words = append(words, "@"+word(tag))
+		}
+		if t.Due != "" {
+			words = append(words, "due:"+strings.Replace(t.Due, " ", "T", 1))
+		}
+		if done && hasPriority {
+			words = append(words, fmt.Sprintf("pri:%c", letter))
+		}
+		if t.Status != "" && t.Status != defaultStatus(done) {
+			words = append(words, "status:"+t.Status)
+		}
+		if _, err := fmt.Fprintln(bw, strings.Join(words, " ")); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// word makes a value a single todo.txt word.
+func word(v string) string {
+	return strings.Join(strings.Fields(v), "_")
+}
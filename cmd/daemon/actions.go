@@ -0,0 +1,93 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benpsk/todo/cmd/notify"
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+// reminderActions are the buttons on a reminder.
+var reminderActions = []notify.Action{
+	{Key: "done", Label: "Done"},
+	{Key: "snooze-10m", Label: "Snooze 10m"},
+	{Key: "snooze-1h", Label: "Snooze 1h"},
+	{Key: "open", Label: "Open"},
+}
+
+// handleAction waits for the action clicked on a reminder of a todo and
+// applies it.
+func (app *App) handleAction(todoID int, actions <-chan string) {
+	action := <-actions
+	switch {
+	case action == "":
+		return
+	case action == "done":
+		if err := app.complete(todoID); err != nil {
+			app.errorf("Action done #%d error: %v", todoID, err)
+			return
+		}
+		fmt.Printf("todo: #%d done\n", todoID)
+	case strings.HasPrefix(action, "snooze-"):
+		d, err := time.ParseDuration(strings.TrimPrefix(action, "snooze-"))
+		if err != nil {
+			app.errorf("Action %s error: %v", action, err)
+			return
+		}
+		at, err := db.Snooze(app.db, todoID, d)
+		if err != nil {
+			app.errorf("Action %s #%d error: %v", action, todoID, err)
+			return
+		}
+		fmt.Printf("todo: #%d snoozed until %s\n", todoID, at.Format("15:04"))
+	case action == "open":
+		if err := openTask(todoID); err != nil {
+			app.errorf("Action open #%d error: %v", todoID, err)
+		}
+	default:
+		app.errorf("Unknown action %q", action)
+	}
+}
+
+// complete moves a todo to the done status, or the first terminal status
+// when the workflow has no done.
+func (app *App) complete(todoID int) error {
+	cfg := config.Get()
+	done, exists := cfg.Status("done")
+	if !exists {
+		terminal := cfg.TerminalStatuses()
+		if len(terminal) == 0 {
+			return fmt.Errorf("no terminal status configured")
+		}
+		done, _ = cfg.Status(strconv.Itoa(terminal[0]))
+	}
+	var value string
+	if err := app.db.QueryRow("select status from todos where id = ?", todoID).Scan(&value); err != nil {
+		return err
+	}
+	if current, exists := cfg.Status(value); exists && !current.Allows(done) {
+		return fmt.Errorf("cannot move from %v to %v", current.Name, done.Name)
+	}
+	return db.Update(app.db, []int{todoID}, map[string]any{"status": done.Value}, db.SourceDaemon)
+}
+
+// openTask runs the configured open command, by default todo show in a
+// terminal.
+func openTask(todoID int) error {
+	command := config.Get().OpenCommand
+	if command == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return err
+		}
+		command = fmt.Sprintf("x-terminal-emulator -e sh -c '%s show {id}; read _'", exe)
+	}
+	command = strings.ReplaceAll(command, "{id}", strconv.Itoa(todoID))
+	return exec.Command("sh", "-c", command).Start()
+}
@@ -0,0 +1,43 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+// backup writes today's backup unless there is one and removes the
+// daily backups past the configured number. Backups made with todo
+// backup are not rotated.
+func (app *App) backup() error {
+	cfg := config.Get().Backup
+	dir := cfg.Directory()
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "todos-"+time.Now().Format("2006-01-02")+".json")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := db.BackupFile(app.db, path); err != nil {
+			return fmt.Errorf("backup: %w", err)
+		}
+		app.log.Info("backup written", "path", path)
+	}
+	daily, err := filepath.Glob(filepath.Join(dir, "todos-[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9].json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(daily)
+	for len(daily) > cfg.Keep {
+		if err := os.Remove(daily[0]); err != nil {
+			return err
+		}
+		app.log.Info("backup removed", "path", daily[0])
+		daily = daily[1:]
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package daemon
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/benpsk/todo/cmd/ical"
+	"github.com/benpsk/todo/config"
+)
+
+// span is a range of minutes since midnight. A span that ends before it
+// starts runs past midnight.
+type span struct{ from, to int }
+
+func (s span) contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if s.from <= s.to {
+		return m >= s.from && m < s.to
+	}
+	return m >= s.from || m < s.to
+}
+
+// calendar is when the daemon may notify.
+type calendar struct {
+	working  map[time.Weekday][]span // nil allows every day
+	holidays map[string]bool         // 2006-01-02
+	yearly   map[string]bool         // 01-02, holidays that recur every year
+	quiet    []span
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+func loadCalendar(cfg config.Calendar) (*calendar, error) {
+	c := &calendar{holidays: map[string]bool{}, yearly: map[string]bool{}}
+	if len(cfg.WorkingHours) > 0 {
+		c.working = map[time.Weekday][]span{}
+		for day, hours := range cfg.WorkingHours {
+			key := strings.ToLower(day)
+			if len(key) > 3 {
+				key = key[:3]
+			}
+			weekday, exists := weekdays[key]
+			if !exists {
+				return nil, fmt.Errorf("working hours: unknown day %q", day)
+			}
+			spans, err := parseSpans(strings.Split(hours, ","))
+			if err != nil {
+				return nil, fmt.Errorf("working hours %s: %w", day, err)
+			}
+			c.working[weekday] = spans
+		}
+	}
+	spans, err := parseSpans(cfg.QuietHours)
+	if err != nil {
+		return nil, fmt.Errorf("quiet hours: %w", err)
+	}
+	c.quiet = spans
+	if cfg.Holidays != "" {
+		if err := c.loadHolidays(cfg.Holidays); err != nil {
+			return nil, fmt.Errorf("holidays: %w", err)
+		}
+	}
+	return c, nil
+}
+
+// parseSpans parses ranges such as 09:00-17:00.
+func parseSpans(ranges []string) ([]span, error) {
+	var spans []span
+	for _, r := range ranges {
+		from, to, found := strings.Cut(strings.TrimSpace(r), "-")
+		if !found {
+			return nil, fmt.Errorf("invalid range %q, want HH:MM-HH:MM", r)
+		}
+		var s span
+		for _, v := range []struct {
+			text string
+			min  *int
+		}{{from, &s.from}, {to, &s.to}} {
+			t, err := time.Parse("15:04", strings.TrimSpace(v.text))
+			if err != nil {
+				return nil, fmt.Errorf("invalid range %q, want HH:MM-HH:MM", r)
+			}
+			*v.min = t.Hour()*60 + t.Minute()
+		}
+		spans = append(spans, s)
+	}
+	return spans, nil
+}
+
+// loadHolidays marks the days covered by the events of an .ics file.
+// Events repeating yearly count every year, other rules are ignored.
+func (c *calendar) loadHolidays(path string) error {
+	if rest, found := strings.CutPrefix(path, "~/"); found {
+		homeDir, _ := os.UserHomeDir()
+		path = filepath.Join(homeDir, rest)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cal, err := ical.Parse(f)
+	if err != nil {
+		return err
+	}
+	for _, event := range cal.All("VEVENT") {
+		start, ok := event.Get("DTSTART")
+		if !ok {
+			continue
+		}
+		from, allDay, err := start.Time()
+		if err != nil {
+			return err
+		}
+		to := from.AddDate(0, 0, 1)
+		if end, ok := event.Get("DTEND"); ok {
+			if to, _, err = end.Time(); err != nil {
+				return err
+			}
+			if !allDay {
+				// a timed event takes the whole of every day it touches
+				to = time.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0, time.Local)
+			}
+		}
+		yearly := strings.Contains(strings.ToUpper(event.Value("RRULE")), "FREQ=YEARLY")
+		for day, n := from, 0; day.Before(to) && n < 366; day, n = day.AddDate(0, 0, 1), n+1 {
+			if yearly {
+				c.yearly[day.Format("01-02")] = true
+			} else {
+				c.holidays[day.Format("2006-01-02")] = true
+			}
+		}
+	}
+	return nil
+}
+
+// suppressed returns why notifications may not be sent at t, "" when
+// they may.
+func (c *calendar) suppressed(t time.Time) string {
+	if c.holidays[t.Format("2006-01-02")] || c.yearly[t.Format("01-02")] {
+		return "holiday"
+	}
+	if c.working != nil {
+		working := false
+		for _, s := range c.working[t.Weekday()] {
+			if s.contains(t) {
+				working = true
+			}
+		}
+		if !working {
+			return "outside working hours"
+		}
+	}
+	for _, s := range c.quiet {
+		if s.contains(t) {
+			return "quiet hours"
+		}
+	}
+	return ""
+}
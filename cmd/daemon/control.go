@@ -0,0 +1,337 @@
+package daemon
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/benpsk/todo/config"
+)
+
+// The control socket speaks JSON-RPC 2.0, one request per line.
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int             `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type Status struct {
+	PID        int         `json:"pid"`
+	StartedAt  time.Time   `json:"started_at"`
+	Uptime     string      `json:"uptime"`
+	DNDUntil   *time.Time  `json:"dnd_until,omitempty"`
+	Suppressed string      `json:"suppressed,omitempty"` // why notifications are held back now
+	Queued     int         `json:"queued"`
+	Jobs       []JobStatus `json:"jobs"`
+	Errors     []JobError  `json:"errors"`
+}
+
+type JobStatus struct {
+	Name                string     `json:"name"`
+	Schedule            string     `json:"schedule"`
+	Next                *time.Time `json:"next,omitempty"`
+	LastRun             *time.Time `json:"last_run,omitempty"`
+	Failures            int        `json:"failures"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+}
+
+type JobError struct {
+	Time    time.Time `json:"time"`
+	Job     string    `json:"job,omitempty"`
+	Message string    `json:"message"`
+}
+
+// failure counts the failed runs of a job.
+type failure struct {
+	total       int
+	consecutive int
+	last        string
+}
+
+// maxErrors is how many recent errors are kept for status.
+const maxErrors = 20
+
+// errorf logs an error outside of a job and keeps it for status.
+func (app *App) errorf(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	app.log.Error(msg)
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	app.recordError("", msg)
+}
+
+// recordError keeps an error for status. The caller holds app.mu.
+func (app *App) recordError(job, msg string) {
+	app.errors = append(app.errors, JobError{Time: time.Now(), Job: job, Message: msg})
+	if len(app.errors) > maxErrors {
+		app.errors = app.errors[len(app.errors)-maxErrors:]
+	}
+}
+
+// serve answers control requests until the listener is closed.
+func (app *App) serve(l net.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				app.errorf("Control socket error: %v", err)
+			}
+			return
+		}
+		go app.serveConn(conn)
+	}
+}
+
+func (app *App) serveConn(conn net.Conn) {
+	defer conn.Close()
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+	for {
+		var req request
+		if err := dec.Decode(&req); err != nil {
+			return
+		}
+		res := response{JSONRPC: "2.0", ID: req.ID}
+		result, err := app.dispatch(req)
+		if err != nil {
+			code := -32000
+			if errors.Is(err, errUnknownMethod) {
+				code = -32601
+			}
+			res.Error = &rpcError{Code: code, Message: err.Error()}
+		} else if res.Result, err = json.Marshal(result); err != nil {
+			res.Error = &rpcError{Code: -32603, Message: err.Error()}
+		}
+		if err := enc.Encode(res); err != nil {
+			return
+		}
+	}
+}
+
+var errUnknownMethod = errors.New("unknown method")
+
+func (app *App) dispatch(req request) (any, error) {
+	var params map[string]string
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return nil, err
+		}
+	}
+	switch req.Method {
+	case "status":
+		return app.status()
+	case "reload":
+		changes, err := app.reload()
+		if err != nil {
+			return nil, err
+		}
+		if len(changes) == 0 {
+			return "reloaded, jobs unchanged", nil
+		}
+		return "reloaded: " + strings.Join(changes, ", "), nil
+	case "run":
+		return "started " + params["job"], app.runJob(params["job"])
+	case "pause":
+		d, err := time.ParseDuration(params["duration"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration %q", params["duration"])
+		}
+		until, err := app.setDND(d)
+		if err != nil {
+			return nil, err
+		}
+		if d <= 0 {
+			return "notifications resumed", nil
+		}
+		return "notifications paused until " + until.Format("2006-01-02 15:04"), nil
+	}
+	return nil, fmt.Errorf("%w %q", errUnknownMethod, req.Method)
+}
+
+func (app *App) status() (Status, error) {
+	now := time.Now()
+	s := Status{Jobs: app.jobStatuses()}
+	until, err := app.stateTime("dnd_until")
+	if err != nil {
+		return s, err
+	}
+	if now.Before(until) {
+		s.DNDUntil = &until
+	}
+	if s.Suppressed, err = app.suppressed(now); err != nil {
+		return s, err
+	}
+	if s.Queued, err = app.queueLength(); err != nil {
+		return s, err
+	}
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	s.PID = os.Getpid()
+	s.StartedAt = app.startedAt
+	s.Uptime = time.Since(app.startedAt).Round(time.Second).String()
+	s.Errors = append([]JobError{}, app.errors...)
+	return s, nil
+}
+
+// reload re-reads the config, reschedules the jobs and logs what
+// changed. A config that does not load, or whose notifiers or jobs are
+// invalid, is rejected and the running one kept.
+func (app *App) reload() ([]string, error) {
+	changes, err := app.apply()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		app.log.Info("job " + c)
+	}
+	app.log.Info("config reloaded", "changes", len(changes))
+	return changes, nil
+}
+
+// apply checks the config file and only then makes it the current one,
+// so running jobs never see a config that is rejected.
+func (app *App) apply() ([]string, error) {
+	cfg, err := config.Read(config.Path())
+	if err != nil {
+		return nil, err
+	}
+	notifier, err := app.newNotifier(cfg, nil)
+	if err != nil {
+		return nil, err
+	}
+	cal, err := loadCalendar(cfg.Calendar)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := app.jobs(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSchedules(jobs); err != nil {
+		return nil, err
+	}
+	config.Set(cfg)
+	changes, err := app.schedule(jobs)
+	if err != nil {
+		return nil, err
+	}
+	app.mu.Lock()
+	app.notifier = notifier
+	app.calendar = cal
+	app.mu.Unlock()
+	return changes, nil
+}
+
+// errNotRunning is returned by call when no daemon listens on the socket.
+var errNotRunning = errors.New("daemon is not running")
+
+// call sends one request to the running daemon.
+func (app *App) call(method string, params any, result any) error {
+	conn, err := net.DialTimeout("unix", app.sockFile, time.Second)
+	if err != nil {
+		return errNotRunning
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req := request{JSONRPC: "2.0", ID: 1, Method: method}
+	if params != nil {
+		if req.Params, err = json.Marshal(params); err != nil {
+			return err
+		}
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return err
+	}
+	var res response
+	if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&res); err != nil {
+		return err
+	}
+	if res.Error != nil {
+		return errors.New(res.Error.Message)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(res.Result, result)
+}
+
+// control sends a command to the running daemon and prints its answer.
+func (app *App) control(method string, params any) {
+	var result string
+	if err := app.call(method, params, &result); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	fmt.Println(result)
+}
+
+func (app *App) printStatus() {
+	var s Status
+	err := app.call("status", nil, &s)
+	if errors.Is(err, errNotRunning) {
+		if app.isDaemonRunning() {
+			fmt.Println("Daemon is running, but its control socket does not answer")
+		} else {
+			fmt.Println("Daemon is not running")
+		}
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Daemon is running (pid %d, up %s)\n", s.PID, s.Uptime)
+	if s.DNDUntil != nil {
+		fmt.Println("Do not disturb until", s.DNDUntil.Format("2006-01-02 15:04"))
+	} else if s.Suppressed != "" {
+		fmt.Println("Notifications held back:", s.Suppressed)
+	}
+	if s.Queued > 0 {
+		fmt.Printf("%d notifications queued\n", s.Queued)
+	}
+	fmt.Println("Jobs:")
+	for _, j := range s.Jobs {
+		next := "-"
+		if j.Next != nil {
+			next = j.Next.Format("2006-01-02 15:04")
+		}
+		fmt.Printf("  %-16s next %s", j.Name, next)
+		if j.Failures > 0 {
+			fmt.Printf("  failures %d (%d in a row)", j.Failures, j.ConsecutiveFailures)
+			if j.ConsecutiveFailures > 0 {
+				fmt.Printf(": %s", j.LastError)
+			}
+		}
+		fmt.Println()
+	}
+	if len(s.Errors) > 0 {
+		fmt.Println("Last errors:")
+		for _, e := range s.Errors {
+			msg := e.Message
+			if e.Job != "" {
+				msg = e.Job + ": " + msg
+			}
+			fmt.Printf("  %s %s\n", e.Time.Format("2006-01-02 15:04:05"), msg)
+		}
+	}
+}
@@ -1,66 +1,103 @@
 package daemon
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
-	"log"
-	"os/exec"
 	"time"
+
+	"github.com/benpsk/todo/cmd/notify"
+	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
 )
 
 type task struct {
 	text string
 }
 
-func (app *App) execute() {
-	title := "Reminder!"
-	msg := "Your tasks: \n"
-	var tasks []task
-	var err error
-	now := time.Now()
-
-	t := fmt.Sprintf("%02d%02d", now.Hour(), now.Minute())
-	switch t {
-	case "0900":
-		title = "Good Morning!"
-		tasks, err = app.getTasks()
-	case "1700":
-		title = "Good Evening!"
-		tasks, err = app.getTasks()
-	default:
-		tasks, err = app.getScheduleTasks()
-	}
+// digest notifies the open tasks matching a configured digest.
+func (app *App) digest(d config.Digest, notifier notify.Notifier) error {
+	tasks, err := app.digestTasks(d.Filter)
 	if err != nil {
-		log.Fatalf("Tasks query error: %v", err)
-		return
+		return fmt.Errorf("digest query: %w", err)
 	}
 	if len(tasks) == 0 {
-		return
+		return nil
 	}
+	title := d.Title
+	if title == "" {
+		title = "Reminder!"
+	}
+	msg := "Your tasks: \n"
 	for _, v := range tasks {
 		msg += v.text + "\n"
 	}
-	err = notify(title, msg)
-	if err != nil {
-		log.Fatalf("Notification error: %v", err)
+	if err := notifier.Notify(notify.Message{Title: title, Body: msg}); err != nil {
+		return fmt.Errorf("notification: %w", err)
 	}
+	return nil
 }
 
-func notify(title, message string) error {
-	cmd := exec.Command("zenity", "--info", "--title="+title, "--text="+message)
-	return cmd.Run()
-}
-
-func (app *App) getScheduleTasks() ([]task, error) {
-	now := time.Now()
-	next := now.Add(time.Minute * 4)
-	formattedNow := now.Format("2006-01-02 15:04")
-	formattedNext := next.Format("2006-01-02 15:04")
+func (app *App) digestTasks(f config.DigestFilter) ([]task, error) {
+	cfg := config.Get()
 	query := `
     select text
-    from todos 
-    where due <= ? and due >= ?
+    from todos
+    where archived_at is null
   `
-	rows, err := app.db.Query(query, formattedNext, formattedNow)
+	var args []interface{}
+	if len(f.Status) > 0 {
+		query += " and status in (" + db.Placeholders(len(f.Status)) + ")"
+		for _, name := range f.Status {
+			status, exists := cfg.Status(name)
+			if !exists {
+				return nil, fmt.Errorf("unknown status %q", name)
+			}
+			args = append(args, status.Value)
+		}
+	} else {
+		terminal := cfg.TerminalStatuses()
+		query += " and status not in (" + db.Placeholders(len(terminal)) + ")"
+		for _, v := range terminal {
+			args = append(args, v)
+		}
+	}
+	if f.Priority != "" {
+		op, name := service.SplitOp(f.Priority)
+		priority, exists := cfg.Priority(name)
+		if !exists {
+			return nil, fmt.Errorf("unknown priority %q", name)
+		}
+		query += " and priority" + op + "?"
+		args = append(args, priority.Weight)
+	}
+	if f.Tag != "" {
+		query += " and tag like ?"
+		args = append(args, "%"+f.Tag+"%")
+	}
+	if f.Project != "" {
+		query += " and project = ?"
+		args = append(args, f.Project)
+	}
+	now := time.Now()
+	switch f.Due {
+	case "", "overdue":
+		query += " and due <= ?"
+		args = append(args, now.Format("2006-01-02 15:04"))
+	case "today":
+		query += " and date(due) <= ?"
+		args = append(args, now.Format("2006-01-02"))
+	case "week":
+		query += " and date(due) <= ?"
+		args = append(args, now.AddDate(0, 0, 7).Format("2006-01-02"))
+	case "any":
+	default:
+		return nil, fmt.Errorf("unknown due filter %q", f.Due)
+	}
+	query += " order by priority desc, due"
+
+	rows, err := app.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -77,27 +114,96 @@ func (app *App) getScheduleTasks() ([]task, error) {
 	return tasks, rows.Err()
 }
 
-func (app *App) getTasks() ([]task, error) {
-	now := time.Now()
-	formattedTime := now.Format("2006-01-02 15:04")
-	query := `
-    select text
-    from todos 
-    where due <= ? and status != ?
-  `
-	rows, err := app.db.Query(query, formattedTime, 3)
+// archiveDone archives tasks that have been in a terminal status for more
+// than the configured number of days.
+func (app *App) archiveDone() error {
+	days := config.Get().ArchiveAfterDays
+	if days <= 0 {
+		return nil
+	}
+	terminal := config.Get().TerminalStatuses()
+	args := []interface{}{fmt.Sprintf("-%d days", days)}
+	for _, v := range terminal {
+		args = append(args, v)
+	}
+	rows, err := app.db.Query(`
+    select id
+    from todos
+    where archived_at is null and updated_at <= datetime('now', ?)
+      and status in (`+db.Placeholders(len(terminal))+`)
+  `, args...)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("archive query: %w", err)
 	}
-	defer rows.Close()
-
-	var tasks []task
+	var ids []int
 	for rows.Next() {
-		var t task
-		if err := rows.Scan(&t.text); err != nil {
-			return nil, err
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("archive query: %w", err)
 		}
-		tasks = append(tasks, t)
+		ids = append(ids, id)
 	}
-	return tasks, rows.Err()
+	rows.Close()
+	if len(ids) == 0 {
+		return nil
+	}
+	archivedAt := time.Now().Format(db.TimeFormat)
+	if err := db.Update(app.db, ids, map[string]any{"archived_at": archivedAt}, db.SourceDaemon); err != nil {
+		return fmt.Errorf("archive: %w", err)
+	}
+	app.log.Info("archived", "ids", ids)
+	return nil
+}
+
+// nagInterval is how often a timer over the limit is nagged about.
+const nagInterval = 30 * time.Minute
+
+// nagTimer notifies when the running timer is over the configured limit.
+func (app *App) nagTimer() error {
+	limit := time.Duration(config.Get().TimerLimit)
+	if limit <= 0 || !app.nagAllowed() {
+		return nil
+	}
+	// the nag repeats once allowed, no need to queue it
+	if reason, err := app.suppressed(time.Now()); err != nil || reason != "" {
+		return err
+	}
+	var text string
+	var startedAt time.Time
+	err := app.db.QueryRow(`
+    select t.text, e.started_at
+    from time_entries e join todos t on t.id = e.todo_id
+    where e.ended_at is null
+  `).Scan(&text, &startedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("timer query: %w", err)
+	}
+	elapsed := time.Since(db.Local(startedAt))
+	if elapsed < limit {
+		return nil
+	}
+	app.mu.Lock()
+	if time.Since(app.lastNag) < nagInterval {
+		// a run started at the same time nagged already
+		app.mu.Unlock()
+		return nil
+	}
+	app.lastNag = time.Now()
+	app.mu.Unlock()
+	msg := fmt.Sprintf("Timer for \"%s\" has been running for %s.\nStop it with: todo stop", text, elapsed.Round(time.Minute))
+	if err := app.defaultNotifier().Notify(notify.Message{Title: "Timer still running", Body: msg}); err != nil {
+		return fmt.Errorf("notification: %w", err)
+	}
+	return nil
+}
+
+// nagAllowed reports whether the last timer nag is nagInterval ago.
+func (app *App) nagAllowed() bool {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	return time.Since(app.lastNag) >= nagInterval
 }
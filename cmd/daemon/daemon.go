@@ -2,33 +2,117 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
+	"time"
+
+	"github.com/benpsk/todo/cmd/api"
+	"github.com/benpsk/todo/cmd/web"
+	"github.com/benpsk/todo/config"
 )
 
-func (app *App) runDaemon() {
+// runDaemon runs until interrupted. Setup errors are returned rather than
+// fatal so the deferred cleanup removes the PID file and socket.
+func (app *App) runDaemon() error {
 	if app.isDaemonRunning() {
-		fmt.Println("Daemon is already running")
-		return
+		return errors.New("daemon is already running")
 	}
 
 	fmt.Println("Starting todo daemon...")
 
+	notifier, err := app.newNotifier(config.Get(), nil)
+	if err != nil {
+		return fmt.Errorf("invalid notifiers: %w", err)
+	}
+	app.notifier = notifier
+	if app.calendar, err = loadCalendar(config.Get().Calendar); err != nil {
+		return fmt.Errorf("invalid calendar: %w", err)
+	}
+	app.startedAt = time.Now()
+
 	// Write PID file
 	if err := app.writePID(); err != nil {
-		log.Fatalf("Failed to write PID file: %v", err)
+		return fmt.Errorf("failed to write PID file: %w", err)
 	}
 	defer app.removePID()
 
 	// Setup cron jobs
-	app.setupCronJobs()
+	jobs, err := app.jobs(config.Get())
+	if err != nil {
+		return fmt.Errorf("invalid jobs: %w", err)
+	}
+	if _, err := app.schedule(jobs); err != nil {
+		return fmt.Errorf("invalid jobs: %w", err)
+	}
 	app.cron.Start()
 	defer app.cron.Stop()
 
+	// Control socket, a stale one is left by a daemon that crashed
+	os.Remove(app.sockFile)
+	l, err := net.Listen("unix", app.sockFile)
+	if err != nil {
+		return fmt.Errorf("failed to open control socket: %w", err)
+	}
+	defer os.Remove(app.sockFile)
+	defer l.Close()
+	go app.serve(l)
+
+	// HTTP API and web UI, when configured
+	if addr := config.Get().API.Addr; addr != "" {
+		token := config.Get().API.Token
+		if err := api.CheckAddr(addr, token); err != nil {
+			return fmt.Errorf("invalid api: %w", err)
+		}
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("failed to listen for the API: %w", err)
+		}
+		mux := http.NewServeMux()
+		mux.Handle("/", api.New(app.db, token))
+		mux.Handle("/ui/", web.New(app.db, token))
+		server := &http.Server{Handler: mux}
+		go func() {
+			if err := server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				app.errorf("API server error: %v", err)
+			}
+		}()
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(ctx)
+		}()
+		app.log.Info("API listening", "addr", addr, "ui", "http://"+addr+"/ui/")
+	}
+
+	// Reload the config on SIGHUP or when the file changes
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	changed, err := watchFile(config.Path())
+	if err != nil {
+		app.errorf("Config watch error, reload with SIGHUP only: %v", err)
+	}
+	go func() {
+		for {
+			select {
+			case <-hup:
+				app.log.Info("reloading config", "reason", "SIGHUP")
+			case <-changed:
+				app.log.Info("reloading config", "reason", "file changed")
+			}
+			if _, err := app.reload(); err != nil {
+				app.errorf("Config reload error: %v", err)
+			}
+		}
+	}()
+
 	fmt.Println("Daemon started. Press Ctrl+C to stop.")
 
 	// Wait for interrupt signal
@@ -44,57 +128,72 @@ func (app *App) runDaemon() {
 
 	<-ctx.Done()
 	fmt.Println("Daemon stopped.")
+	return nil
 }
 
+// isDaemonRunning reports whether a daemon holds the lock on the PID
+// file. A PID left behind by a daemon that died, and since reused by
+// another process, does not count.
 func (app *App) isDaemonRunning() bool {
-	data, err := os.ReadFile(app.pidFile)
+	_, running := app.runningPID()
+	return running
+}
+
+// runningPID returns the PID of the running daemon.
+func (app *App) runningPID() (int, bool) {
+	f, err := os.Open(app.pidFile)
 	if err != nil {
-		return false
+		return 0, false
 	}
-
-	pid, err := strconv.Atoi(string(data))
+	defer f.Close()
+	data, err := io.ReadAll(f)
 	if err != nil {
-		return false
+		return 0, false
 	}
-
-	// Check if process is still running
-	process, err := os.FindProcess(pid)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
 	if err != nil {
-		return false
+		return 0, false
 	}
-
-	// On Unix systems, sending signal 0 checks if process exists
-	err = process.Signal(syscall.Signal(0))
-	return err == nil
+	return pid, locked(f, pid)
 }
 
+// writePID writes the PID file and holds an exclusive lock on it for as
+// long as the daemon runs.
 func (app *App) writePID() error {
-	pid := os.Getpid()
-	return os.WriteFile(app.pidFile, []byte(strconv.Itoa(pid)), 0644)
+	f, err := os.OpenFile(app.pidFile, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	if err := lock(f); err != nil {
+		f.Close()
+		return errors.New("another daemon holds the lock")
+	}
+	if err := f.Truncate(0); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.WriteString(strconv.Itoa(os.Getpid())); err != nil {
+		f.Close()
+		return err
+	}
+	app.pidLock = f
+	return nil
 }
 
 func (app *App) removePID() error {
-	return os.Remove(app.pidFile)
+	os.Remove(app.pidFile)
+	return app.pidLock.Close()
 }
 
+// stopDaemon signals the daemon to stop and waits until it released the
+// PID file lock.
 func (app *App) stopDaemon() {
-	if !app.isDaemonRunning() {
+	pid, running := app.runningPID()
+	if !running {
 		fmt.Println("Daemon is not running")
 		return
 	}
 
-	data, err := os.ReadFile(app.pidFile)
-	if err != nil {
-		fmt.Printf("Failed to read PID file: %v\n", err)
-		return
-	}
-
-	pid, err := strconv.Atoi(string(data))
-	if err != nil {
-		fmt.Printf("Invalid PID in file: %v\n", err)
-		return
-	}
-
 	process, err := os.FindProcess(pid)
 	if err != nil {
 		fmt.Printf("Failed to find process: %v\n", err)
@@ -106,12 +205,14 @@ func (app *App) stopDaemon() {
 		return
 	}
 
+	// The lock is released once the daemon has cleaned up and exited.
+	deadline := time.Now().Add(10 * time.Second)
+	for app.isDaemonRunning() {
+		if time.Now().After(deadline) {
+			fmt.Printf("Daemon (pid %d) did not stop in time\n", pid)
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
 	fmt.Println("Daemon stopped.")
 }
-
-func (app *App) setupCronJobs() {
-	app.cron.AddFunc("*/5 * * * *", func() {
-		fmt.Println("todo cron: run schedule!")
-		app.execute()
-	})
-}
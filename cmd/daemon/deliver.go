@@ -0,0 +1,146 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/benpsk/todo/cmd/notify"
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+// tick is how often deliver runs.
+const tick = time.Minute
+
+// pending is a reminder or a timed due date that has not been notified.
+type pending struct {
+	reminderID int // 0 for a due date
+	todoID     int
+	text       string
+	at         time.Time
+}
+
+// deliver notifies every reminder and timed due date whose time has come
+// and marks it fired, so each is delivered exactly once even when a tick
+// is late. Anything that should have fired while the daemon was down or
+// the machine asleep is batched into one "missed while away"
+// notification. Failed notifications stay pending for the next try.
+func (app *App) deliver() error {
+	now := time.Now()
+	lastRun, err := app.stateTime("last_run")
+	if err != nil {
+		return fmt.Errorf("daemon state: %w", err)
+	}
+	items, err := app.pending(now)
+	if err != nil {
+		return fmt.Errorf("pending query: %w", err)
+	}
+	var errs []error
+	// the first run counts as away too, due dates from before the daemon
+	// or the due_fired_at column existed are batched rather than sent one
+	// by one
+	away := lastRun.IsZero() || now.Sub(lastRun) > 2*tick
+	var missed []pending
+	for _, p := range items {
+		if away && p.at.Before(now.Add(-tick)) {
+			missed = append(missed, p)
+			continue
+		}
+		title := fmt.Sprintf("Due: #%d", p.todoID)
+		if p.reminderID != 0 {
+			title = fmt.Sprintf("Reminder: #%d", p.todoID)
+		}
+		actions, err := notify.Send(app.defaultNotifier(), notify.Message{Title: title, Body: p.text, Actions: reminderActions})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notification #%d: %w", p.todoID, err))
+			continue
+		}
+		if err := app.markFired(p, now); err != nil {
+			errs = append(errs, err)
+		}
+		if actions != nil {
+			go app.handleAction(p.todoID, actions)
+		}
+	}
+	if len(missed) > 0 {
+		var body strings.Builder
+		for _, p := range missed {
+			fmt.Fprintf(&body, "%s #%d %s\n", p.at.Format("01-02 15:04"), p.todoID, p.text)
+		}
+		title := fmt.Sprintf("Missed while away (%d)", len(missed))
+		err := app.defaultNotifier().Notify(notify.Message{Title: title, Body: body.String()})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notification: %w", err))
+		} else {
+			for _, p := range missed {
+				if err := app.markFired(p, now); err != nil {
+					errs = append(errs, err)
+				}
+			}
+		}
+	}
+	if err := app.setState("last_run", now.Format(db.TimeFormat)); err != nil {
+		errs = append(errs, fmt.Errorf("daemon state: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// pending returns the unfired reminders and timed due dates up to now of
+// tasks that are still open, oldest first.
+func (app *App) pending(now time.Time) ([]pending, error) {
+	terminal := config.Get().TerminalStatuses()
+	args := []interface{}{now.Format(db.TimeFormat)}
+	for _, v := range terminal {
+		args = append(args, v)
+	}
+	args = append(args, now.Format(db.TimeFormat))
+	for _, v := range terminal {
+		args = append(args, v)
+	}
+	rows, err := app.db.Query(`
+    select r.id, r.todo_id, t.text, `+db.RemindAt+` as at
+    from reminders r join todos t on t.id = r.todo_id
+    where r.fired_at is null and at <= ? and t.archived_at is null
+      and t.status not in (`+db.Placeholders(len(terminal))+`)
+    union all
+    select 0, id, text, datetime(due)
+    from todos
+    where length(due) > 10 and datetime(due) <= ? and archived_at is null
+      and (due_fired_at is null or due_fired_at < datetime(due))
+      and status not in (`+db.Placeholders(len(terminal))+`)
+    order by at
+  `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []pending
+	for rows.Next() {
+		var p pending
+		var at string
+		if err := rows.Scan(&p.reminderID, &p.todoID, &p.text, &at); err != nil {
+			return nil, err
+		}
+		if p.at, err = time.ParseInLocation(db.TimeFormat, at, time.Local); err != nil {
+			return nil, err
+		}
+		items = append(items, p)
+	}
+	return items, rows.Err()
+}
+
+func (app *App) markFired(p pending, now time.Time) error {
+	var err error
+	if p.reminderID != 0 {
+		_, err = app.db.Exec("update reminders set fired_at = ? where id = ?", now.Format(db.TimeFormat), p.reminderID)
+	} else {
+		_, err = app.db.Exec("update todos set due_fired_at = ? where id = ?", now.Format(db.TimeFormat), p.todoID)
+	}
+	if err != nil {
+		return fmt.Errorf("fired marker: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// detach starts the daemon again as a background process in its own
+// session, with its output appended to the log file.
+func (app *App) detach() {
+	if app.isDaemonRunning() {
+		fmt.Println("Daemon is already running")
+		return
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	logFile, err := os.OpenFile(app.logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(exe, "daemon", "start")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = detached()
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	// Wait until the daemon holds its lock, or tell why it did not start.
+	deadline := time.After(5 * time.Second)
+	for !app.isDaemonRunning() {
+		select {
+		case <-exited:
+			fmt.Fprintf(os.Stderr, "Daemon exited on start, see %s\n", app.logFile)
+			os.Exit(1)
+		case <-deadline:
+			fmt.Fprintf(os.Stderr, "Daemon did not start in time, see %s\n", app.logFile)
+			os.Exit(1)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	fmt.Printf("Daemon started in the background (pid %d), logging to %s\n", cmd.Process.Pid, app.logFile)
+}
+
+// logs prints the end of the daemon log, and with -f keeps printing what
+// is appended to it.
+func (app *App) logs() {
+	fs := flag.NewFlagSet("daemon logs", flag.ExitOnError)
+	follow := fs.Bool("f", false, "Keep printing new lines")
+	lines := fs.Int("n", 20, "Number of lines to print")
+	fs.Parse(os.Args[3:])
+
+	f, err := os.Open(app.logFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	var tail []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		tail = append(tail, scanner.Text())
+		if len(tail) > *lines {
+			tail = tail[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	for _, line := range tail {
+		fmt.Println(line)
+	}
+	if !*follow {
+		return
+	}
+	for {
+		if _, err := io.Copy(os.Stdout, f); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
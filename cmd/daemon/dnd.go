@@ -0,0 +1,99 @@
+package daemon
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+// forever is how long do not disturb lasts when turned on without --for.
+const forever = 100 * 365 * 24 * time.Hour
+
+// DND turns do not disturb on and off. It is kept in the database, so it
+// works whether the daemon runs or not and is the same as daemon pause.
+func (app *App) DND() {
+	if len(os.Args) < 3 {
+		dndUsage()
+	}
+	switch os.Args[2] {
+	case "on":
+		fs := flag.NewFlagSet("dnd on", flag.ExitOnError)
+		d := fs.Duration("for", forever, "How long, e.g. 2h. Default until turned off")
+		fs.Parse(os.Args[3:])
+		if *d <= 0 {
+			fmt.Fprintln(os.Stderr, "--for must be positive")
+			os.Exit(1)
+		}
+		until, err := app.setDND(*d)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Do not disturb", formatUntil(until))
+	case "off":
+		if _, err := app.setDND(0); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		fmt.Println("Do not disturb off, queued notifications are sent within a minute")
+	case "status":
+		app.printDND()
+	default:
+		dndUsage()
+	}
+}
+
+func dndUsage() {
+	fmt.Println("usage: todo dnd on [--for=2h] | off | status")
+	os.Exit(1)
+}
+
+// setDND holds notifications back for d, d <= 0 ends it.
+func (app *App) setDND(d time.Duration) (time.Time, error) {
+	if d <= 0 {
+		return time.Time{}, app.setState("dnd_until", "")
+	}
+	until := time.Now().Add(d)
+	return until, app.setState("dnd_until", until.Format(db.TimeFormat))
+}
+
+func formatUntil(until time.Time) string {
+	if until.After(time.Now().Add(forever / 2)) {
+		return "on until turned off"
+	}
+	return "on until " + until.Format("2006-01-02 15:04")
+}
+
+func (app *App) printDND() {
+	cal, err := loadCalendar(config.Get().Calendar)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	now := time.Now()
+	until, err := app.stateTime("dnd_until")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if now.Before(until) {
+		fmt.Println("Do not disturb", formatUntil(until))
+	} else {
+		fmt.Println("Do not disturb off")
+	}
+	if reason := cal.suppressed(now); reason != "" {
+		fmt.Println("Notifications held back now:", reason)
+	}
+	n, err := app.queueLength()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if n > 0 {
+		fmt.Printf("%d notifications queued\n", n)
+	}
+}
@@ -0,0 +1,185 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/benpsk/todo/cmd/notify"
+	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+// escalation is a configured escalation step, resolved.
+type escalation struct {
+	config.Escalation
+	key      string // identifies the step in escalations rows
+	op       string
+	weight   int
+	notifier notify.Notifier // nil uses the default notifier
+}
+
+// escalations resolves the configured escalation steps, so a bad one is
+// rejected when the config loads rather than on every tick.
+func (app *App) escalations(cfg *config.Config) ([]escalation, error) {
+	var steps []escalation
+	for i, e := range cfg.Escalations {
+		op, name := service.SplitOp(e.Priority)
+		priority, exists := cfg.Priority(name)
+		if !exists {
+			return nil, fmt.Errorf("escalation %d: unknown priority %q", i+1, name)
+		}
+		switch e.Urgency {
+		case "":
+			e.Urgency = notify.Normal
+		case notify.Low, notify.Normal, notify.Critical:
+		default:
+			return nil, fmt.Errorf("escalation %d: unknown urgency %q", i+1, e.Urgency)
+		}
+		// the index moves when steps are added or reordered
+		key := fmt.Sprintf("%s after %s every %s", e.Priority, time.Duration(e.After), time.Duration(e.Every))
+		step := escalation{Escalation: e, key: key, op: op, weight: priority.Weight}
+		if len(e.Notifiers) > 0 {
+			var err error
+			if step.notifier, err = app.newNotifier(cfg, e.Notifiers); err != nil {
+				return nil, fmt.Errorf("escalation %d: %w", i+1, err)
+			}
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func (e escalation) matches(weight int) bool {
+	switch e.op {
+	case ">":
+		return weight > e.weight
+	case ">=":
+		return weight >= e.weight
+	case "<":
+		return weight < e.weight
+	case "<=":
+		return weight <= e.weight
+	case "!=":
+		return weight != e.weight
+	}
+	return weight == e.weight
+}
+
+type overdue struct {
+	todoID   int
+	text     string
+	priority int
+	due      string
+	dueAt    time.Time
+}
+
+// escalate re-notifies overdue tasks at the step they have reached. Each
+// notification is recorded, so a step fires once, or once per its Every,
+// for as long as the task stays overdue on the same due date.
+func (app *App) escalate(steps []escalation) error {
+	now := time.Now()
+	// overdue tasks are notified again once allowed, no need to queue
+	if reason, err := app.suppressed(now); err != nil || reason != "" {
+		return err
+	}
+	tasks, err := app.overdue(now)
+	if err != nil {
+		return fmt.Errorf("overdue query: %w", err)
+	}
+	var errs []error
+	for _, t := range tasks {
+		late := now.Sub(t.dueAt)
+		step := -1
+		for i, e := range steps {
+			if e.matches(t.priority) && late >= time.Duration(e.After) &&
+				(step < 0 || e.After > steps[step].After) {
+				step = i
+			}
+		}
+		if step < 0 {
+			continue
+		}
+		e := steps[step]
+		var last *string
+		err := app.db.QueryRow(`
+      select max(notified_at)
+      from escalations
+      where todo_id = ? and due = ? and (key = ? or key is null and step = ?)
+    `, t.todoID, t.due, e.key, step).Scan(&last)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("escalation query #%d: %w", t.todoID, err))
+			continue
+		}
+		if last != nil {
+			at, err := time.ParseInLocation(db.TimeFormat, *last, time.Local)
+			if err == nil && (e.Every <= 0 || now.Sub(at) < time.Duration(e.Every)) {
+				continue
+			}
+		}
+
+		notifier := e.notifier
+		if notifier == nil {
+			notifier = app.defaultNotifier()
+		}
+		msg := notify.Message{
+			Title:   fmt.Sprintf("Overdue %s: #%d", service.FormatDuration(late), t.todoID),
+			Body:    t.text,
+			Urgency: e.Urgency,
+			Actions: reminderActions,
+		}
+		actions, err := notify.Send(notifier, msg)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notification #%d: %w", t.todoID, err))
+			continue
+		}
+		_, err = app.db.Exec(`
+      insert into escalations(todo_id, step, key, due, notified_at) values(?,?,?,?,?)
+    `, t.todoID, step, e.key, t.due, now.Format(db.TimeFormat))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("escalation marker #%d: %w", t.todoID, err))
+		}
+		if actions != nil {
+			go app.handleAction(t.todoID, actions)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// overdue returns the open tasks past their due date. A due date without
+// a time is overdue once that day is over. Tasks with a pending reminder,
+// such as a snoozed one, wait for it.
+func (app *App) overdue(now time.Time) ([]overdue, error) {
+	terminal := config.Get().TerminalStatuses()
+	args := []interface{}{now.Format(db.TimeFormat)}
+	for _, v := range terminal {
+		args = append(args, v)
+	}
+	rows, err := app.db.Query(`
+    select id, text, priority, due || '',
+      case when length(due) > 10 then datetime(due) else datetime(due, '+1 day') end as due_at
+    from todos t
+    where due is not null and due != '' and due_at <= ? and archived_at is null
+      and status not in (`+db.Placeholders(len(terminal))+`)
+      and not exists (select 1 from reminders r where r.todo_id = t.id and r.fired_at is null)
+  `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tasks []overdue
+	for rows.Next() {
+		var t overdue
+		var dueAt string
+		if err := rows.Scan(&t.todoID, &t.text, &t.priority, &t.due, &dueAt); err != nil {
+			return nil, err
+		}
+		if t.dueAt, err = time.ParseInLocation(db.TimeFormat, dueAt, time.Local); err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, t)
+	}
+	return tasks, rows.Err()
+}
@@ -2,45 +2,99 @@ package daemon
 
 import (
 	"database/sql"
+	"flag"
 	"fmt"
+	"log"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
+	"github.com/benpsk/todo/cmd/notify"
 	"github.com/robfig/cron/v3"
 )
 
 type App struct {
-	pidFile string
-	cron    *cron.Cron
-	db      *sql.DB
+	pidFile  string
+	sockFile string
+	logFile  string
+	pidLock  *os.File
+	cron     *cron.Cron
+	db       *sql.DB
+	notifier notify.Notifier
+	log      *slog.Logger
+
+	// state reported on the control socket
+	mu        sync.Mutex
+	startedAt time.Time
+	scheduled map[string]job
+	entries   map[string]cron.EntryID
+	runs      map[string]time.Time
+	failures  map[string]failure
+	errors    []JobError
+	calendar  *calendar
+	lastNag   time.Time // guarded by mu too
 }
 
 func New(db *sql.DB) *App {
 	homeDir, _ := os.UserHomeDir()
 	return &App{
-		pidFile: filepath.Join(homeDir, ".todo.pid"),
-		cron:    cron.New(),
-		db:      db,
+		pidFile:   filepath.Join(homeDir, ".todo.pid"),
+		sockFile:  filepath.Join(homeDir, ".todo.sock"),
+		logFile:   filepath.Join(homeDir, ".todo.log"),
+		cron:      cron.New(),
+		db:        db,
+		log:       slog.New(slog.NewTextHandler(os.Stderr, nil)),
+		scheduled: map[string]job{},
+		entries:   map[string]cron.EntryID{},
+		runs:      map[string]time.Time{},
+		failures:  map[string]failure{},
 	}
 }
 
 func (app *App) Handle() {
 	if len(os.Args) < 3 {
-		fmt.Println("daemon status | start | stop")
+		fmt.Println("daemon status | start [--detach] | stop | jobs | reload | run <job> | pause <duration> | logs [-f] | install [--autostart]")
 		os.Exit(1)
 	}
 	cmd := os.Args[2]
 	switch cmd {
 	case "status":
-		if app.isDaemonRunning() {
-			fmt.Println("Daemon is running")
-		} else {
-			fmt.Println("Daemon is not running")
-		}
+		app.printStatus()
 	case "start":
-		app.runDaemon()
+		fs := flag.NewFlagSet("daemon start", flag.ExitOnError)
+		detach := fs.Bool("detach", false, "Run in the background, logging to ~/.todo.log")
+		fs.Parse(os.Args[3:])
+		if *detach {
+			app.detach()
+			return
+		}
+		if err := app.runDaemon(); err != nil {
+			log.Fatal(err)
+		}
 	case "stop":
 		app.stopDaemon()
+	case "jobs":
+		app.printJobs()
+	case "reload":
+		app.control("reload", nil)
+	case "run":
+		if len(os.Args) < 4 {
+			fmt.Println("usage: todo daemon run <job>")
+			os.Exit(1)
+		}
+		app.control("run", map[string]string{"job": os.Args[3]})
+	case "logs":
+		app.logs()
+	case "install":
+		app.install()
+	case "pause":
+		if len(os.Args) < 4 {
+			fmt.Println("usage: todo daemon pause <duration> (0 resumes)")
+			os.Exit(1)
+		}
+		app.control("pause", map[string]string{"duration": os.Args[3]})
 	default:
 		os.Exit(1)
 	}
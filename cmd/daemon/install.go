@@ -0,0 +1,119 @@
+package daemon
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+const systemdUnit = `[Unit]
+Description=todo reminder daemon
+
+[Service]
+ExecStart=%s daemon start
+Restart=on-failure
+StandardOutput=append:%s
+StandardError=append:%s
+
+[Install]
+WantedBy=default.target
+`
+
+const autostartEntry = `[Desktop Entry]
+Type=Application
+Name=todo daemon
+Comment=todo reminder daemon
+Exec=%s daemon start --detach
+Terminal=false
+X-GNOME-Autostart-enabled=true
+`
+
+// install starts the daemon with the session: as a systemd user service
+// when a user manager runs, otherwise as an XDG autostart entry.
+func (app *App) install() {
+	fs := flag.NewFlagSet("daemon install", flag.ExitOnError)
+	autostart := fs.Bool("autostart", false, "Write an XDG autostart entry even when systemd is available")
+	fs.Parse(os.Args[3:])
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+	if !*autostart && hasSystemdUser() {
+		err = app.installSystemd(exe)
+	} else {
+		err = installAutostart(exe)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// hasSystemdUser reports whether a systemd user manager is reachable.
+func hasSystemdUser() bool {
+	if _, err := exec.LookPath("systemctl"); err != nil {
+		return false
+	}
+	return exec.Command("systemctl", "--user", "is-system-running").Run() == nil ||
+		exec.Command("systemctl", "--user", "show-environment").Run() == nil
+}
+
+func (app *App) installSystemd(exe string) error {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "systemd", "user", "todo.service")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	unit := fmt.Sprintf(systemdUnit, exe, app.logFile, app.logFile)
+	if err := os.WriteFile(path, []byte(unit), 0644); err != nil {
+		return err
+	}
+	fmt.Println("Wrote", path)
+	if app.isDaemonRunning() {
+		fmt.Println("Stopping the running daemon so systemd can take over")
+		app.stopDaemon()
+		if app.isDaemonRunning() {
+			return errors.New("the running daemon did not stop, stop it with: todo daemon stop")
+		}
+	}
+	for _, args := range [][]string{
+		{"--user", "daemon-reload"},
+		{"--user", "enable", "--now", "todo.service"},
+	} {
+		out, err := exec.Command("systemctl", args...).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("systemctl %v: %v: %s", args, err, out)
+		}
+	}
+	fmt.Println("Enabled and started todo.service, see: systemctl --user status todo")
+	return nil
+}
+
+func installAutostart(exe string) error {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, "autostart", "todo.desktop")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(fmt.Sprintf(autostartEntry, exe)), 0644); err != nil {
+		return err
+	}
+	fmt.Println("Wrote", path)
+	fmt.Println("The daemon starts with your next session, or now with: todo daemon start --detach")
+	return nil
+}
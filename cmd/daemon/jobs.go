@@ -0,0 +1,249 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/benpsk/todo/config"
+	"github.com/robfig/cron/v3"
+)
+
+type job struct {
+	name string
+	spec string
+	run  func() error
+}
+
+// jobs returns the built-in jobs followed by the configured digests.
+func (app *App) jobs(cfg *config.Config) ([]job, error) {
+	jobs := []job{
+		{name: "deliver", spec: "* * * * *", run: app.deliver},
+		{name: "timer", spec: "*/5 * * * *", run: app.nagTimer},
+		{name: "archive", spec: "0 * * * *", run: app.archiveDone},
+		{name: "flush", spec: "* * * * *", run: app.flush},
+	}
+	steps, err := app.escalations(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(steps) > 0 {
+		jobs = append(jobs, job{name: "escalate", spec: "* * * * *", run: func() error { return app.escalate(steps) }})
+	}
+	if cfg.Backup.Keep > 0 {
+		jobs = append(jobs, job{name: "backup", spec: "0 * * * *", run: app.backup})
+	}
+	for _, d := range cfg.Digests {
+		notifier, err := app.newNotifier(cfg, d.Notifiers)
+		if err != nil {
+			return nil, fmt.Errorf("digest %s: %w", d.Name, err)
+		}
+		jobs = append(jobs, job{
+			name: "digest:" + d.Name,
+			spec: d.Schedule,
+			run:  func() error { return app.digest(d, notifier) },
+		})
+	}
+	return jobs, nil
+}
+
+// checkSchedules parses the schedule of every job.
+func checkSchedules(jobs []job) error {
+	for _, j := range jobs {
+		if _, err := cron.ParseStandard(j.spec); err != nil {
+			return fmt.Errorf("invalid schedule %q for job %s: %w", j.spec, j.name, err)
+		}
+	}
+	return nil
+}
+
+// schedule brings the cron entries in line with jobs: new jobs are added,
+// jobs no longer configured removed and jobs whose schedule changed
+// replaced. Entries left alone run the new job from their next tick.
+// Every schedule is checked first so a bad one leaves the running jobs
+// untouched. It returns what changed.
+func (app *App) schedule(jobs []job) ([]string, error) {
+	if err := checkSchedules(jobs); err != nil {
+		return nil, err
+	}
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	var changes []string
+	wanted := map[string]bool{}
+	for _, j := range jobs {
+		wanted[j.name] = true
+	}
+	for name, id := range app.entries {
+		if !wanted[name] {
+			app.cron.Remove(id)
+			delete(app.entries, name)
+			delete(app.scheduled, name)
+			changes = append(changes, "removed "+name)
+		}
+	}
+	for _, j := range jobs {
+		old, exists := app.scheduled[j.name]
+		app.scheduled[j.name] = j
+		if exists && old.spec == j.spec {
+			continue
+		}
+		if exists {
+			app.cron.Remove(app.entries[j.name])
+		}
+		id, err := app.cron.AddFunc(j.spec, app.track(j.name))
+		if err != nil {
+			return changes, err
+		}
+		app.entries[j.name] = id
+		if exists {
+			changes = append(changes, fmt.Sprintf("replaced %s (%s -> %s)", j.name, old.spec, j.spec))
+		} else {
+			changes = append(changes, fmt.Sprintf("added %s (%s)", j.name, j.spec))
+		}
+	}
+	return changes, nil
+}
+
+// Failed runs are retried with exponential backoff before the job counts
+// as failed until its next scheduled run.
+const (
+	maxAttempts  = 3
+	retryBackoff = 2 * time.Second
+)
+
+// track runs the job currently scheduled under name and records when it
+// last ran and how it failed. An error or panic in a run is logged and
+// retried, never fatal to the daemon.
+func (app *App) track(name string) func() {
+	return func() {
+		app.mu.Lock()
+		j, exists := app.scheduled[name]
+		if !exists {
+			app.mu.Unlock()
+			return
+		}
+		app.runs[j.name] = time.Now()
+		app.mu.Unlock()
+
+		backoff := retryBackoff
+		var err error
+		for attempt := 1; attempt <= maxAttempts; attempt++ {
+			if err = safeRun(j.run); err == nil {
+				break
+			}
+			app.log.Warn("job failed", "job", j.name, "attempt", attempt, "err", err)
+			if attempt < maxAttempts {
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+		}
+		app.mu.Lock()
+		defer app.mu.Unlock()
+		f := app.failures[j.name]
+		if err == nil {
+			f.consecutive = 0
+			app.failures[j.name] = f
+			return
+		}
+		f.total++
+		f.consecutive++
+		f.last = err.Error()
+		app.failures[j.name] = f
+		app.recordError(j.name, err.Error())
+		app.log.Error("job gave up", "job", j.name, "attempts", maxAttempts,
+			"failures", f.total, "consecutive", f.consecutive, "err", err)
+	}
+}
+
+// safeRun turns a panic in run into an error.
+func safeRun(run func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run()
+}
+
+// runJob runs a scheduled job now, in the background.
+func (app *App) runJob(name string) error {
+	app.mu.Lock()
+	_, exists := app.scheduled[name]
+	app.mu.Unlock()
+	if !exists {
+		return fmt.Errorf("unknown job %q", name)
+	}
+	go app.track(name)()
+	return nil
+}
+
+// jobStatuses reports the scheduled jobs sorted by their next run.
+func (app *App) jobStatuses() []JobStatus {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	var statuses []JobStatus
+	for name, id := range app.entries {
+		s := JobStatus{Name: name, Schedule: app.scheduled[name].spec}
+		if next := app.cron.Entry(id).Next; !next.IsZero() {
+			s.Next = &next
+		}
+		if run, exists := app.runs[name]; exists {
+			s.LastRun = &run
+		}
+		f := app.failures[name]
+		s.Failures, s.ConsecutiveFailures, s.LastError = f.total, f.consecutive, f.last
+		statuses = append(statuses, s)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Next == nil || statuses[j].Next == nil {
+			return statuses[i].Name < statuses[j].Name
+		}
+		return statuses[i].Next.Before(*statuses[j].Next)
+	})
+	return statuses
+}
+
+// printJobs lists every job with its next fire time, as scheduled by the
+// running daemon or else from the config.
+func (app *App) printJobs() {
+	var statuses []JobStatus
+	var status Status
+	err := app.call("status", nil, &status)
+	if err == nil {
+		statuses = status.Jobs
+	} else if errors.Is(err, errNotRunning) {
+		jobs, err := app.jobs(config.Get())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		now := time.Now()
+		for _, j := range jobs {
+			s := JobStatus{Name: j.name, Schedule: j.spec}
+			if schedule, err := cron.ParseStandard(j.spec); err == nil {
+				next := schedule.Next(now)
+				s.Next = &next
+			}
+			statuses = append(statuses, s)
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "job\tschedule\tnext\tlast run\tfailures")
+	for _, s := range statuses {
+		next, lastRun := "invalid schedule", "-"
+		if s.Next != nil {
+			next = s.Next.Format("2006-01-02 15:04")
+		}
+		if s.LastRun != nil {
+			lastRun = s.LastRun.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", s.Name, s.Schedule, next, lastRun, s.Failures)
+	}
+	w.Flush()
+}
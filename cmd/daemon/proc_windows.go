@@ -0,0 +1,27 @@
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+// lock is a no-op, a daemon is known to be running by its PID alone.
+func lock(f *os.File) error {
+	return nil
+}
+
+// locked reports whether the process pid still exists.
+func locked(f *os.File, pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+// detached starts a process in a group of its own, so Ctrl+C in the
+// terminal does not reach it.
+func detached() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
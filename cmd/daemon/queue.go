@@ -0,0 +1,193 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/benpsk/todo/cmd/notify"
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+// newNotifier builds a notifier for the configured notifiers with the
+// given names, all of them when empty, that holds notifications back
+// while the daemon may not notify.
+func (app *App) newNotifier(cfg *config.Config, names []string) (notify.Notifier, error) {
+	cfgs, err := cfg.NotifiersNamed(names)
+	if err != nil {
+		return nil, err
+	}
+	n, err := notify.New(cfgs)
+	if err != nil {
+		return nil, err
+	}
+	return &gate{app: app, names: names, notifier: n}, nil
+}
+
+// gate queues notifications during do not disturb and outside the
+// calendar, for the flush job to send at the next allowed time. Queued
+// notifications lose their actions.
+type gate struct {
+	app      *App
+	names    []string
+	notifier notify.Notifier
+}
+
+func (g *gate) Name() string { return g.notifier.Name() }
+
+func (g *gate) Notify(msg notify.Message) error {
+	reason, err := g.app.suppressed(time.Now())
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		return g.app.enqueue(g.names, msg)
+	}
+	return g.notifier.Notify(msg)
+}
+
+func (g *gate) NotifyActions(msg notify.Message) (<-chan string, error) {
+	reason, err := g.app.suppressed(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if reason != "" {
+		return nil, g.app.enqueue(g.names, msg)
+	}
+	return notify.Send(g.notifier, msg)
+}
+
+// defaultNotifier returns the notifier for everything but digests.
+func (app *App) defaultNotifier() notify.Notifier {
+	app.mu.Lock()
+	defer app.mu.Unlock()
+	return app.notifier
+}
+
+// suppressed returns why notifications may not be sent at t, "" when
+// they may.
+func (app *App) suppressed(t time.Time) (string, error) {
+	until, err := app.stateTime("dnd_until")
+	if err != nil {
+		return "", err
+	}
+	if t.Before(until) {
+		return "do not disturb", nil
+	}
+	app.mu.Lock()
+	cal := app.calendar
+	app.mu.Unlock()
+	if cal == nil {
+		return "", nil
+	}
+	return cal.suppressed(t), nil
+}
+
+func (app *App) enqueue(names []string, msg notify.Message) error {
+	if msg.Urgency == "" {
+		msg.Urgency = notify.Normal
+	}
+	_, err := app.db.Exec(`
+    insert into notification_queue(notifiers, title, body, urgency, queued_at) values(?,?,?,?,?)
+  `, strings.Join(names, ","), msg.Title, msg.Body, msg.Urgency, time.Now().Format(db.TimeFormat))
+	return err
+}
+
+type queued struct {
+	id       int
+	notifier string
+	msg      notify.Message
+	at       time.Time
+}
+
+// flush sends the queued notifications once notifying is allowed again,
+// one per notifier or a summary when several wait for the same one.
+func (app *App) flush() error {
+	if reason, err := app.suppressed(time.Now()); err != nil || reason != "" {
+		return err
+	}
+	rows, err := app.db.Query(`
+    select id, notifiers, title, body, urgency, queued_at
+    from notification_queue
+    order by id
+  `)
+	if err != nil {
+		return fmt.Errorf("queue query: %w", err)
+	}
+	var order []string
+	groups := map[string][]queued{}
+	for rows.Next() {
+		var q queued
+		if err := rows.Scan(&q.id, &q.notifier, &q.msg.Title, &q.msg.Body, &q.msg.Urgency, &q.at); err != nil {
+			rows.Close()
+			return fmt.Errorf("queue query: %w", err)
+		}
+		q.at = db.Local(q.at)
+		if _, exists := groups[q.notifier]; !exists {
+			order = append(order, q.notifier)
+		}
+		groups[q.notifier] = append(groups[q.notifier], q)
+	}
+	rows.Close()
+
+	var errs []error
+	for _, names := range order {
+		group := groups[names]
+		var list []string
+		if names != "" {
+			list = strings.Split(names, ",")
+		}
+		cfgs, err := config.Get().NotifiersNamed(list)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		n, err := notify.New(cfgs)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := n.Notify(summary(group)); err != nil {
+			errs = append(errs, fmt.Errorf("notification: %w", err))
+			continue
+		}
+		ids := make([]interface{}, len(group))
+		for i, q := range group {
+			ids[i] = q.id
+		}
+		if _, err := app.db.Exec("delete from notification_queue where id in ("+db.Placeholders(len(ids))+")", ids...); err != nil {
+			errs = append(errs, fmt.Errorf("queue delete: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// summary is the notification for a group of queued ones, at the highest
+// urgency among them.
+func summary(group []queued) notify.Message {
+	if len(group) == 1 {
+		return group[0].msg
+	}
+	rank := map[string]int{notify.Low: 0, notify.Normal: 1, notify.Critical: 2}
+	msg := notify.Message{Title: fmt.Sprintf("Held back (%d)", len(group)), Urgency: notify.Low}
+	var body strings.Builder
+	for _, q := range group {
+		fmt.Fprintf(&body, "%s %s\n", q.at.Format("15:04"), q.msg.Title)
+		for _, line := range strings.Split(strings.TrimSpace(q.msg.Body), "\n") {
+			fmt.Fprintf(&body, "  %s\n", line)
+		}
+		if rank[q.msg.Urgency] > rank[msg.Urgency] {
+			msg.Urgency = q.msg.Urgency
+		}
+	}
+	msg.Body = body.String()
+	return msg
+}
+
+func (app *App) queueLength() (int, error) {
+	var n int
+	err := app.db.QueryRow("select count(*) from notification_queue").Scan(&n)
+	return n, err
+}
@@ -0,0 +1,36 @@
+package daemon
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/benpsk/todo/db"
+)
+
+// state returns a persisted daemon value, "" when it was never set.
+func (app *App) state(key string) (string, error) {
+	var value string
+	err := app.db.QueryRow("select value from daemon_state where key = ?", key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	return value, err
+}
+
+func (app *App) setState(key, value string) error {
+	_, err := app.db.Exec(`
+    insert into daemon_state(key, value) values(?,?)
+    on conflict(key) do update set value = excluded.value
+  `, key, value)
+	return err
+}
+
+// stateTime returns a persisted timestamp, the zero time when never set.
+func (app *App) stateTime(key string) (time.Time, error) {
+	value, err := app.state(key)
+	if err != nil || value == "" {
+		return time.Time{}, err
+	}
+	return time.ParseInLocation(db.TimeFormat, value, time.Local)
+}
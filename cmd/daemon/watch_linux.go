@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"bytes"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// settle is how long a burst of events is collapsed for, since editors
+// write a file in several steps.
+const settle = 300 * time.Millisecond
+
+// watchFile sends on the returned channel whenever path is written,
+// created, replaced or removed. The directory is watched rather than the
+// file, so a file that editors replace by renaming keeps being watched.
+func watchFile(path string) (<-chan struct{}, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return nil, err
+	}
+	mask := uint32(syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_MOVED_TO |
+		syscall.IN_MOVED_FROM | syscall.IN_DELETE)
+	if _, err := syscall.InotifyAddWatch(fd, filepath.Dir(path), mask); err != nil {
+		syscall.Close(fd)
+		return nil, err
+	}
+	name := filepath.Base(path)
+	events := make(chan struct{}, 1)
+	go func() {
+		defer syscall.Close(fd)
+		buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+		for {
+			n, err := syscall.Read(fd, buf)
+			if err != nil {
+				if err == syscall.EINTR {
+					continue
+				}
+				return
+			}
+			for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+				event := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+				start := offset + syscall.SizeofInotifyEvent
+				offset = start + int(event.Len)
+				if string(bytes.TrimRight(buf[start:offset], "\x00")) != name {
+					continue
+				}
+				select {
+				case events <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	// Collapse bursts into one change
+	changed := make(chan struct{})
+	go func() {
+		for range events {
+		burst:
+			for {
+				select {
+				case <-events:
+				case <-time.After(settle):
+					break burst
+				}
+			}
+			changed <- struct{}{}
+		}
+	}()
+	return changed, nil
+}
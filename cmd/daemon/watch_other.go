@@ -0,0 +1,10 @@
+//go:build !linux
+
+package daemon
+
+import "errors"
+
+// watchFile is only implemented with inotify, elsewhere reload with SIGHUP.
+func watchFile(path string) (<-chan struct{}, error) {
+	return nil, errors.New("config watching needs Linux")
+}
@@ -5,9 +5,9 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"strings"
 
 	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/db"
 )
 
 func (app *App) delete() {
@@ -26,14 +26,5 @@ func (app *App) delete() {
 }
 
 func (app *App) deleteTodo(ids []int) error {
-	placeholders := make([]string, len(ids))
-	args := make([]interface{}, len(ids))
-	for i, id := range ids {
-		placeholders[i] = "?"
-		args[i] = id
-	}
-	query := "DELETE FROM todos WHERE id IN (" + strings.Join(placeholders, ",") + ")"
-
-	_, err := app.db.Exec(query, args...)
-	return err
+	return db.Delete(app.db, ids, db.SourceCLI)
 }
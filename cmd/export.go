@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benpsk/todo/cmd/convert"
+	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+func (app *App) export() {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "todotxt", "Format: "+strings.Join(convert.Formats, ", "))
+	output := fs.String("output", "", "File to write, default stdout")
+	fs.StringVar(output, "o", "", "File to write, default stdout")
+	asEvents := fs.Bool("as-events", false, "With ics, write tasks due at a time as events")
+	groupBy := fs.String("group-by", "none", "With markdown, a heading per: "+strings.Join(convert.MarkdownGroups, ", "))
+	cmd := newFilter(service.Parse(fs, "export"))
+	if isValid := service.Validate(cmd); !isValid {
+		os.Exit(1)
+	}
+	if isValid := service.IsValidCreated(cmd); !isValid {
+		fmt.Fprintf(os.Stderr, "Invalid created date %v\n", cmd.Created)
+		os.Exit(1)
+	}
+	if !slices.Contains(convert.Formats, *format) {
+		fmt.Fprintf(os.Stderr, "Unknown format %q, use one of %s\n", *format, strings.Join(convert.Formats, ", "))
+		os.Exit(1)
+	}
+	if !slices.Contains(convert.MarkdownGroups, *groupBy) {
+		fmt.Fprintf(os.Stderr, "Unknown group %q, use one of %s\n", *groupBy, strings.Join(convert.MarkdownGroups, ", "))
+		os.Exit(1)
+	}
+	tasks, err := app.exportable(cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		w = f
+	}
+	switch *format {
+	case "todotxt":
+		err = convert.WriteTodoTxt(w, tasks)
+	case "taskwarrior":
+		err = convert.WriteTaskwarrior(w, tasks)
+	case "ics":
+		err = convert.WriteICS(w, tasks, *asEvents)
+	case "markdown":
+		err = convert.WriteMarkdown(w, tasks, *groupBy)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *output != "" {
+		fmt.Printf("Exported %d tasks to %s\n", len(tasks), *output)
+	}
+}
+
+// exportable returns the tasks matching the list filters, every one that
+// is not archived by default.
+func (app *App) exportable(cmd *service.Filter) ([]convert.Task, error) {
+	where, args := cmd.Where()
+	query := `
+    SELECT id, coalesce(uuid, ''), text, priority, status, due || '', coalesce(tag, ''),
+      coalesce(project, ''), coalesce(recur, ''), created_at, updated_at
+    FROM todos
+  ` + where
+	if cmd.Archived {
+		query += " AND archived_at IS NOT NULL"
+	} else {
+		query += " AND archived_at IS NULL"
+	}
+	query += " ORDER BY id"
+	rows, err := app.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	cfg := config.Get()
+	var tasks []convert.Task
+	for rows.Next() {
+		var t convert.Task
+		var priority, status int
+		var due *string
+		var tags string
+		var updated time.Time
+		if err := rows.Scan(&t.ID, &t.UUID, &t.Text, &priority, &status, &due, &tags,
+			&t.Project, &t.Recur, &t.Created, &updated); err != nil {
+			return nil, err
+		}
+		if s, exists := cfg.Status(strconv.Itoa(status)); exists {
+			t.Status = s.Name
+			if s.Terminal {
+				t.Finished = updated.Local()
+			}
+		}
+		if p, exists := cfg.Priority(strconv.Itoa(priority)); exists && p.Weight > 0 {
+			t.Priority = p.Name
+		}
+		if due != nil {
+			t.Due = db.Due(*due)
+		}
+		for _, tag := range strings.Split(tags, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				t.Tags = append(t.Tags, tag)
+			}
+		}
+		t.Created = t.Created.Local()
+		t.Modified = updated.Local()
+		tasks = append(tasks, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return tasks, app.related(tasks)
+}
+
+// related adds the notes, dependencies and unfired reminders of tasks.
+func (app *App) related(tasks []convert.Task) error {
+	byID := map[int]*convert.Task{}
+	for i := range tasks {
+		byID[tasks[i].ID] = &tasks[i]
+	}
+	rows, err := app.db.Query("SELECT todo_id, text, created_at FROM notes ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int
+		var n convert.Note
+		if err := rows.Scan(&id, &n.Text, &n.Time); err != nil {
+			return err
+		}
+		if t, exists := byID[id]; exists {
+			n.Time = n.Time.Local()
+			t.Notes = append(t.Notes, n)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	deps, err := app.db.Query(`
+    SELECT d.todo_id, t.uuid
+    FROM dependencies d JOIN todos t ON t.id = d.depends_on
+    ORDER BY d.todo_id, d.depends_on
+  `)
+	if err != nil {
+		return err
+	}
+	defer deps.Close()
+	for deps.Next() {
+		var id int
+		var uuid string
+		if err := deps.Scan(&id, &uuid); err != nil {
+			return err
+		}
+		if t, exists := byID[id]; exists {
+			t.Depends = append(t.Depends, uuid)
+		}
+	}
+	if err := deps.Err(); err != nil {
+		return err
+	}
+	reminders, err := app.db.Query("SELECT todo_id, remind_at, before FROM reminders WHERE fired_at IS NULL ORDER BY id")
+	if err != nil {
+		return err
+	}
+	defer reminders.Close()
+	for reminders.Next() {
+		var id int
+		var at *time.Time
+		var before *int
+		if err := reminders.Scan(&id, &at, &before); err != nil {
+			return err
+		}
+		t, exists := byID[id]
+		if !exists {
+			continue
+		}
+		var r convert.Reminder
+		if at != nil {
+			r.At = db.Local(*at)
+		} else if before != nil {
+			r.Before = time.Duration(*before) * time.Second
+		}
+		t.Reminders = append(t.Reminders, r)
+	}
+	return reminders.Err()
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/db"
+)
+
+func (app *App) history() {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	fs.Parse(os.Args[2:])
+	if fs.NArg() != 1 {
+		fmt.Println("usage: todo history <id>")
+		os.Exit(1)
+	}
+	id := service.ValidateIds(fs.Args())[0]
+	changes, err := db.History(app.db, id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(changes) == 0 {
+		fmt.Println("No history for id:", id)
+		return
+	}
+	for _, c := range changes {
+		printChange(c)
+	}
+}
+
+func (app *App) undo() {
+	fs := flag.NewFlagSet("undo", flag.ExitOnError)
+	fs.Parse(os.Args[2:])
+	n := 1
+	if fs.NArg() > 0 {
+		v, err := strconv.Atoi(fs.Arg(0))
+		if err != nil || v < 1 {
+			fmt.Println("usage: todo undo [n]")
+			os.Exit(1)
+		}
+		n = v
+	}
+	changes, err := db.Undo(app.db, n)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(changes) == 0 {
+		fmt.Println("Nothing to undo")
+		return
+	}
+	for _, c := range changes {
+		fmt.Print("Undone: ")
+		printChange(c)
+	}
+}
+
+func printChange(c db.Change) {
+	var undone string
+	if c.Undone {
+		undone = " (undone)"
+	}
+	fmt.Printf("#%-4d %s  %-6s %-6s id:%d%s\n",
+		c.ID, c.CreatedAt.Format("2006-01-02 15:04:05"), c.Op, c.Source, c.TodoID, undone)
+	if c.Op == "create" || c.Op == "delete" {
+		return
+	}
+	cols := make([]string, 0, len(c.Diff))
+	for col := range c.Diff {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	for _, col := range cols {
+		fmt.Printf("      %-10s %v -> %v\n", col, display(c.Diff[col][0]), display(c.Diff[col][1]))
+	}
+}
+
+func display(v any) any {
+	if v == nil {
+		return "-"
+	}
+	return v
+}
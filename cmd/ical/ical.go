@@ -0,0 +1,175 @@
+// Package ical reads and writes iCalendar (RFC 5545) files, as far as todo
+// needs: components, their properties and dates.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+type Component struct {
+	Name       string
+	Properties []Property
+	Children   []*Component
+}
+
+// Get returns the first property called name.
+func (c *Component) Get(name string) (Property, bool) {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return Property{}, false
+}
+
+// Value returns the value of the first property called name, "" when
+// there is none.
+func (c *Component) Value(name string) string {
+	p, _ := c.Get(name)
+	return p.Value
+}
+
+// All returns the children called name, at any depth.
+func (c *Component) All(name string) []*Component {
+	var found []*Component
+	for _, child := range c.Children {
+		if child.Name == name {
+			found = append(found, child)
+		}
+		found = append(found, child.All(name)...)
+	}
+	return found
+}
+
+// Parse reads a calendar. The returned component holds every top-level
+// component, usually one VCALENDAR.
+func Parse(r io.Reader) (*Component, error) {
+	root := &Component{}
+	stack := []*Component{root}
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, err
+	}
+	for n, line := range lines {
+		if line == "" {
+			continue
+		}
+		p, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		top := stack[len(stack)-1]
+		switch p.Name {
+		case "BEGIN":
+			c := &Component{Name: strings.ToUpper(p.Value)}
+			top.Children = append(top.Children, c)
+			stack = append(stack, c)
+		case "END":
+			if len(stack) == 1 || top.Name != strings.ToUpper(p.Value) {
+				return nil, fmt.Errorf("line %d: unexpected END:%s", n+1, p.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			top.Properties = append(top.Properties, p)
+		}
+	}
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("missing END:%s", stack[len(stack)-1].Name)
+	}
+	return root, nil
+}
+
+// unfold joins continuation lines, which start with a space or a tab.
+func unfold(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if len(lines) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// parseLine splits NAME;PARAM=value:VALUE. Quoted parameter values may
+// hold ; and :.
+func parseLine(line string) (Property, error) {
+	p := Property{Params: map[string]string{}}
+	i := strings.IndexAny(line, ";:")
+	if i < 0 {
+		return p, fmt.Errorf("invalid content line %q", line)
+	}
+	p.Name = strings.ToUpper(line[:i])
+	rest := line[i:]
+	for strings.HasPrefix(rest, ";") {
+		rest = rest[1:]
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			return p, fmt.Errorf("invalid parameter in %q", line)
+		}
+		key := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				return p, fmt.Errorf("unterminated quote in %q", line)
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, ";:")
+			if end < 0 {
+				return p, fmt.Errorf("invalid content line %q", line)
+			}
+			value, rest = rest[:end], rest[end:]
+		}
+		p.Params[key] = value
+	}
+	if !strings.HasPrefix(rest, ":") {
+		return p, fmt.Errorf("invalid content line %q", line)
+	}
+	p.Value = rest[1:]
+	return p, nil
+}
+
+// Time parses a DATE or DATE-TIME value. UTC times end in Z, a TZID is
+// looked up and anything else is local. allDay is true for a DATE.
+func (p Property) Time() (t time.Time, allDay bool, err error) {
+	loc := time.Local
+	if tzid, exists := p.Params["TZID"]; exists {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+	v := p.Value
+	switch {
+	case p.Params["VALUE"] == "DATE" || len(v) == 8:
+		t, err = time.ParseInLocation("20060102", v, time.Local)
+		return t, true, err
+	case strings.HasSuffix(v, "Z"):
+		t, err = time.Parse("20060102T150405Z", v)
+		return t.Local(), false, err
+	default:
+		t, err = time.ParseInLocation("20060102T150405", v, loc)
+		return t.Local(), false, err
+	}
+}
+
+// Text unescapes a TEXT value.
+func Text(v string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(v)
+}
@@ -0,0 +1,157 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Add appends a property without parameters.
+func (c *Component) Add(name, value string) {
+	c.Properties = append(c.Properties, Property{Name: name, Value: value})
+}
+
+// Write writes c and its children as content lines folded at 75 octets.
+// A component without a name, as returned by Parse, writes only its
+// children.
+func Write(w io.Writer, c *Component) error {
+	bw := bufio.NewWriter(w)
+	write(bw, c)
+	return bw.Flush()
+}
+
+func write(w *bufio.Writer, c *Component) {
+	if c.Name != "" {
+		writeLine(w, "BEGIN:"+c.Name)
+	}
+	for _, p := range c.Properties {
+		line := p.Name
+		keys := make([]string, 0, len(p.Params))
+		for key := range p.Params {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := p.Params[key]
+			if strings.ContainsAny(value, ";:,") {
+				value = `"` + value + `"`
+			}
+			line += ";" + key + "=" + value
+		}
+		writeLine(w, line+":"+p.Value)
+	}
+	for _, child := range c.Children {
+		write(w, child)
+	}
+	if c.Name != "" {
+		writeLine(w, "END:"+c.Name)
+	}
+}
+
+// writeLine folds a line, continuation lines start with a space and
+// runes are not split.
+func writeLine(w *bufio.Writer, line string) {
+	limit := 75
+	for len(line) > limit {
+		i := limit
+		for i > 0 && !utf8.RuneStart(line[i]) {
+			i--
+		}
+		w.WriteString(line[:i] + "\r\n ")
+		line = line[i:]
+		limit = 74
+	}
+	w.WriteString(line + "\r\n")
+}
+
+// Escape escapes a TEXT value, the reverse of Text.
+func Escape(v string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(v)
+}
+
+// DateTime formats t as a UTC DATE-TIME.
+func DateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// Date formats t as a DATE.
+func Date(t time.Time) string {
+	return t.Format("20060102")
+}
+
+// FormatDuration formats d as a DURATION such as -PT30M or P1D.
+func FormatDuration(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	if d == 0 {
+		return "PT0S"
+	}
+	var b strings.Builder
+	b.WriteString(sign + "P")
+	if days := d / (24 * time.Hour); days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+	if d == 0 {
+		return b.String()
+	}
+	b.WriteString("T")
+	for _, unit := range []struct {
+		d      time.Duration
+		letter string
+	}{{time.Hour, "H"}, {time.Minute, "M"}, {time.Second, "S"}} {
+		if n := d / unit.d; n > 0 {
+			fmt.Fprintf(&b, "%d%s", n, unit.letter)
+			d -= n * unit.d
+		}
+	}
+	return b.String()
+}
+
+// ParseDuration parses a DURATION such as -PT30M, P1DT2H or P1W.
+func ParseDuration(v string) (time.Duration, error) {
+	s := v
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, s = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, fmt.Errorf("invalid duration %q", v)
+	}
+	s = s[1:]
+	units := map[byte]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour}
+	var d time.Duration
+	n := -1
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= '0' && c <= '9':
+			if n < 0 {
+				n = 0
+			}
+			n = n*10 + int(c-'0')
+		case c == 'T':
+			units = map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+		default:
+			unit, exists := units[c]
+			if !exists || n < 0 {
+				return 0, fmt.Errorf("invalid duration %q", v)
+			}
+			d += time.Duration(n) * unit
+			n = -1
+		}
+	}
+	if n >= 0 {
+		return 0, fmt.Errorf("invalid duration %q", v)
+	}
+	return sign * d, nil
+}
@@ -0,0 +1,300 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/benpsk/todo/cmd/convert"
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+func (app *App) importTasks() {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	format := fs.String("format", "todotxt", "Format: "+strings.Join(convert.Formats, ", "))
+	dryRun := fs.Bool("dry-run", false, "Only report what would be imported")
+	headings := fs.String("headings", "project", "With markdown, read headings as: "+strings.Join(convert.MarkdownHeadings, ", "))
+	fs.Parse(os.Args[2:])
+	if fs.NArg() != 1 {
+		fmt.Println("usage: todo import [--format=FORMAT] [--headings=project|tag|none] [--dry-run] FILE|-")
+		os.Exit(1)
+	}
+	if !slices.Contains(convert.Formats, *format) {
+		fmt.Fprintf(os.Stderr, "Unknown format %q, use one of %s\n", *format, strings.Join(convert.Formats, ", "))
+		os.Exit(1)
+	}
+	if !slices.Contains(convert.MarkdownHeadings, *headings) {
+		fmt.Fprintf(os.Stderr, "Unknown headings %q, use one of %s\n", *headings, strings.Join(convert.MarkdownHeadings, ", "))
+		os.Exit(1)
+	}
+	var r io.Reader = os.Stdin
+	if path := fs.Arg(0); path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var tasks []convert.Task
+	var problems []convert.Problem
+	var err error
+	switch *format {
+	case "todotxt":
+		tasks, problems, err = convert.ReadTodoTxt(r)
+	case "taskwarrior":
+		tasks, problems, err = convert.ReadTaskwarrior(r)
+	case "ics":
+		tasks, problems, err = convert.ReadICS(r)
+	case "markdown":
+		tasks, problems, err = convert.ReadMarkdown(r, *headings)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	res, err := app.store(tasks, *dryRun)
+	if err != nil {
+		log.Fatal(err)
+	}
+	problems = append(problems, res.problems...)
+	verb := "Imported"
+	if *dryRun {
+		verb = "Would import"
+	}
+	fmt.Printf("%s: %d added, %d updated, %d unchanged\n", verb, res.added, res.updated, res.unchanged)
+	if len(problems) > 0 {
+		fmt.Fprintf(os.Stderr, "%d could not be mapped:\n", len(problems))
+		for _, p := range problems {
+			fmt.Fprintln(os.Stderr, "  "+p.String())
+		}
+	}
+}
+
+type stored struct {
+	added, updated, unchanged int
+	problems                  []convert.Problem
+}
+
+// store adds imported tasks, or updates the ones whose UUID is already
+// stored so importing again does not duplicate them. Dependencies are
+// linked once every task is stored.
+func (app *App) store(tasks []convert.Task, dryRun bool) (stored, error) {
+	var res stored
+	ids := map[string]int{}
+	for _, t := range tasks {
+		fields, err := importable(t)
+		if err != nil {
+			res.problems = append(res.problems, convert.Problem{Text: t.Text, Reason: err.Error()})
+			continue
+		}
+		id, exists, err := app.idOfUUID(t.UUID)
+		if err != nil {
+			return res, err
+		}
+		if exists {
+			// created_at is kept and updated_at only moves on a change
+			delete(fields, "created_at")
+			delete(fields, "updated_at")
+			if fields, err = app.changed(id, fields); err != nil {
+				return res, err
+			}
+		}
+		switch {
+		case exists && len(fields) == 0:
+			res.unchanged++
+		case exists:
+			res.updated++
+			if !dryRun {
+				err = db.Update(app.db, []int{id}, fields, db.SourceCLI)
+			}
+		default:
+			res.added++
+			if t.UUID != "" {
+				fields["uuid"] = t.UUID
+			}
+			if !dryRun {
+				id, err = db.Insert(app.db, fields, db.SourceCLI)
+			}
+		}
+		if err != nil {
+			return res, err
+		}
+		if dryRun {
+			continue
+		}
+		if t.UUID != "" {
+			ids[t.UUID] = id
+		}
+		if err := app.addNotes(id, t.Notes); err != nil {
+			return res, err
+		}
+		if err := app.addReminders(id, t.Reminders); err != nil {
+			return res, err
+		}
+	}
+	if dryRun {
+		return res, nil
+	}
+	for _, t := range tasks {
+		id, exists := ids[t.UUID]
+		if !exists || t.Depends == nil {
+			continue
+		}
+		if _, err := app.db.Exec("delete from dependencies where todo_id = ?", id); err != nil {
+			return res, err
+		}
+		for _, uuid := range t.Depends {
+			dep, found, err := app.idOfUUID(uuid)
+			if err != nil {
+				return res, err
+			}
+			if !found {
+				res.problems = append(res.problems, convert.Problem{Text: t.Text, Reason: "unknown dependency " + uuid})
+				continue
+			}
+			if _, err := app.db.Exec("insert or ignore into dependencies(todo_id, depends_on) values(?,?)", id, dep); err != nil {
+				return res, err
+			}
+		}
+	}
+	return res, nil
+}
+
+// changed returns the fields whose value differs from the stored todo.
+func (app *App) changed(id int, fields map[string]any) (map[string]any, error) {
+	cols := make([]string, 0, len(fields))
+	for col := range fields {
+		cols = append(cols, col)
+	}
+	exprs := make([]string, len(cols))
+	olds := make([]*string, len(cols))
+	values := make([]any, len(cols))
+	for i, col := range cols {
+		exprs[i] = col + " || ''"
+		values[i] = &olds[i]
+	}
+	err := app.db.QueryRow("select "+strings.Join(exprs, ", ")+" from todos where id = ?", id).Scan(values...)
+	if err != nil {
+		return nil, err
+	}
+	diff := map[string]any{}
+	for i, col := range cols {
+		old := olds[i]
+		if v := fields[col]; v == nil {
+			// todo add writes an empty tag
+			if old != nil && *old != "" {
+				diff[col] = nil
+			}
+		} else if old == nil || *old != fmt.Sprint(v) {
+			diff[col] = v
+		}
+	}
+	return diff, nil
+}
+
+func (app *App) idOfUUID(uuid string) (int, bool, error) {
+	if uuid == "" {
+		return 0, false, nil
+	}
+	var id int
+	err := app.db.QueryRow("select id from todos where uuid = ?", uuid).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	return id, err == nil, err
+}
+
+// addNotes adds the notes a task does not have yet.
+func (app *App) addNotes(id int, notes []convert.Note) error {
+	for _, n := range notes {
+		at := db.UTC(n.Time)
+		_, err := app.db.Exec(`
+      insert into notes(todo_id, text, created_at)
+      select ?, ?, ?
+      where not exists (select 1 from notes where todo_id = ? and text = ? and created_at = ?)
+    `, id, n.Text, at, id, n.Text, at)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addReminders adds the reminders a todo does not have yet.
+func (app *App) addReminders(id int, reminders []convert.Reminder) error {
+	for _, r := range reminders {
+		var err error
+		if r.At.IsZero() {
+			_, err = app.db.Exec(`
+        insert into reminders(todo_id, before)
+        select ?, ?
+        where not exists (select 1 from reminders where todo_id = ? and before = ?)
+      `, id, int(r.Before.Seconds()), id, int(r.Before.Seconds()))
+		} else {
+			at := r.At.Format(db.TimeFormat)
+			_, err = app.db.Exec(`
+        insert into reminders(todo_id, remind_at)
+        select ?, ?
+        where not exists (select 1 from reminders where todo_id = ? and remind_at = ?)
+      `, id, at, id, at)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importable turns a task read from another format into todos columns.
+func importable(t convert.Task) (map[string]any, error) {
+	cfg := config.Get()
+	fields := map[string]any{"text": t.Text, "priority": 0, "due": nil, "tag": "", "project": nil}
+	name := t.Status
+	if name == "" && len(cfg.Statuses) > 0 {
+		name = cfg.Statuses[0].Name
+	}
+	status, exists := cfg.Status(name)
+	if !exists {
+		return nil, fmt.Errorf("unknown status %q", t.Status)
+	}
+	fields["status"] = status.Value
+	if t.Priority != "" {
+		p, exists := cfg.Priority(t.Priority)
+		if !exists {
+			return nil, fmt.Errorf("unknown priority %q", t.Priority)
+		}
+		fields["priority"] = p.Weight
+	}
+	if t.Due != "" {
+		fields["due"] = t.Due
+	}
+	if len(t.Tags) > 0 {
+		fields["tag"] = strings.Join(t.Tags, ",")
+	}
+	if t.Project != "" {
+		fields["project"] = t.Project
+	}
+	// formats without recurrence leave it as it is
+	if t.Recur != "" {
+		fields["recur"] = t.Recur
+	}
+	if !t.Created.IsZero() {
+		fields["created_at"] = db.UTC(t.Created)
+		fields["updated_at"] = fields["created_at"]
+	}
+	if !t.Finished.IsZero() && status.Terminal {
+		fields["updated_at"] = db.UTC(t.Finished)
+	}
+	if !t.Modified.IsZero() {
+		fields["updated_at"] = db.UTC(t.Modified)
+	}
+	return fields, nil
+}
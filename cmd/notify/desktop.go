@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+type notifySend struct{ name string }
+
+func (n *notifySend) Name() string { return n.name }
+
+func (n *notifySend) Notify(msg Message) error {
+	path, err := lookPath("notify-send")
+	if err != nil {
+		return err
+	}
+	return exec.Command(path, "--app-name=todo", "--urgency="+msg.Urgency, msg.Title, msg.Body).Run()
+}
+
+// startupWait is how long notify-send gets to fail, without a display or
+// session bus it exits at once.
+const startupWait = 500 * time.Millisecond
+
+// NotifyActions waits in the background for the clicked action, which
+// notify-send prints on stdout. A notify-send that fails right away is
+// an error, so the message goes to the other notifiers.
+func (n *notifySend) NotifyActions(msg Message) (<-chan string, error) {
+	path, err := lookPath("notify-send")
+	if err != nil {
+		return nil, err
+	}
+	args := []string{"--app-name=todo", "--urgency=" + msg.Urgency, "--wait"}
+	for _, a := range msg.Actions {
+		args = append(args, "--action="+a.Key+"="+a.Label)
+	}
+	cmd := exec.Command(path, append(args, msg.Title, msg.Body)...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	ch := make(chan string, 1)
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, fmt.Errorf("notify-send: %w", err)
+		}
+		ch <- strings.TrimSpace(out.String())
+		close(ch)
+		return ch, nil
+	case <-time.After(startupWait):
+	}
+	go func() {
+		defer close(ch)
+		<-done
+		ch <- strings.TrimSpace(out.String())
+	}()
+	return ch, nil
+}
+
+// dbus calls org.freedesktop.Notifications directly through gdbus, for
+// desktops without notify-send.
+type dbus struct{ name string }
+
+func (n *dbus) Name() string { return n.name }
+
+func (n *dbus) Notify(msg Message) error {
+	path, err := lookPath("gdbus")
+	if err != nil {
+		return err
+	}
+	urgency := map[string]int{Low: 0, Normal: 1, Critical: 2}[msg.Urgency]
+	return exec.Command(path, "call", "--session",
+		"--dest", "org.freedesktop.Notifications",
+		"--object-path", "/org/freedesktop/Notifications",
+		"--method", "org.freedesktop.Notifications.Notify",
+		"todo", "0", "", msg.Title, msg.Body, "[]",
+		fmt.Sprintf("{'urgency': <byte %d>}", urgency), "-1").Run()
+}
+
+// zenity shows a tray notification, the modal --info dialog blocked the
+// daemon until it was dismissed.
+type zenity struct{ name string }
+
+func (n *zenity) Name() string { return n.name }
+
+func (n *zenity) Notify(msg Message) error {
+	path, err := lookPath("zenity")
+	if err != nil {
+		return err
+	}
+	return exec.Command(path, "--notification", "--text="+msg.Title+"\n"+msg.Body).Run()
+}
+
+// bell rings the terminal bell and prints the message on stdout.
+type bell struct{ name string }
+
+func (n *bell) Name() string { return n.name }
+
+func (n *bell) Notify(msg Message) error {
+	_, err := fmt.Fprintf(os.Stdout, "\a%s\n%s\n", msg.Title, msg.Body)
+	return err
+}
+
+// wall broadcasts to every logged in terminal.
+type wall struct{ name string }
+
+func (n *wall) Name() string { return n.name }
+
+func (n *wall) Notify(msg Message) error {
+	path, err := lookPath("wall")
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(path)
+	cmd.Stdin = strings.NewReader(msg.Title + "\n" + msg.Body + "\n")
+	return cmd.Run()
+}
@@ -0,0 +1,191 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+
+	"github.com/benpsk/todo/config"
+)
+
+// Urgency levels, following the freedesktop notification spec.
+const (
+	Low      = "low"
+	Normal   = "normal"
+	Critical = "critical"
+)
+
+type Message struct {
+	Title   string
+	Body    string
+	Urgency string
+	Actions []Action
+}
+
+// Action is a button on a notification.
+type Action struct {
+	Key   string
+	Label string
+}
+
+type Notifier interface {
+	Name() string
+	Notify(Message) error
+}
+
+// Actioner is a notifier that can show the message actions. The channel
+// receives the key of the clicked action, or "" when the notification was
+// dismissed, and is then closed.
+type Actioner interface {
+	NotifyActions(Message) (<-chan string, error)
+}
+
+// Send delivers msg with its actions when the notifier supports them.
+// The channel is nil when the actions could not be shown.
+func Send(n Notifier, msg Message) (<-chan string, error) {
+	if a, ok := n.(Actioner); ok && len(msg.Actions) > 0 {
+		return a.NotifyActions(msg)
+	}
+	return nil, n.Notify(msg)
+}
+
+// ErrUnavailable is returned by a notifier whose binary is missing.
+var ErrUnavailable = errors.New("notifier unavailable")
+
+// fallbacks are tried in order when none of the configured notifiers
+// could deliver a message.
+var fallbacks = []config.Notifier{
+	{Type: "notify-send"},
+	{Type: "dbus"},
+	{Type: "bell"},
+	{Type: "log"},
+}
+
+// New builds a notifier that delivers to every configured notifier.
+func New(cfgs []config.Notifier) (Notifier, error) {
+	m := &multi{}
+	for _, cfg := range cfgs {
+		n, err := build(cfg)
+		if err != nil {
+			return nil, err
+		}
+		m.notifiers = append(m.notifiers, n)
+	}
+	for _, cfg := range fallbacks {
+		n, _ := build(cfg)
+		m.fallbacks = append(m.fallbacks, n)
+	}
+	return m, nil
+}
+
+func build(cfg config.Notifier) (Notifier, error) {
+	name := cfg.Name
+	if name == "" {
+		name = cfg.Type
+	}
+	switch cfg.Type {
+	case "notify-send":
+		return &notifySend{name: name}, nil
+	case "dbus":
+		return &dbus{name: name}, nil
+	case "zenity":
+		return &zenity{name: name}, nil
+	case "bell":
+		return &bell{name: name}, nil
+	case "wall":
+		return &wall{name: name}, nil
+	case "log":
+		return newLogFile(name, cfg.Path), nil
+	case "webhook":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("notifier %s: url is required", name)
+		}
+		return &webhook{name: name, url: cfg.URL, token: cfg.Token}, nil
+	case "ntfy":
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("notifier %s: url is required (e.g., https://ntfy.sh/my-topic)", name)
+		}
+		return &ntfy{name: name, url: cfg.URL, token: cfg.Token}, nil
+	case "smtp":
+		if cfg.Host == "" || len(cfg.To) == 0 {
+			return nil, fmt.Errorf("notifier %s: host and to are required", name)
+		}
+		return &smtp{name: name, cfg: cfg}, nil
+	}
+	return nil, fmt.Errorf("notifier %s: unknown type %q", name, cfg.Type)
+}
+
+type multi struct {
+	notifiers []Notifier
+	fallbacks []Notifier
+}
+
+func (m *multi) Name() string { return "multi" }
+
+// Notify delivers to every notifier. When none succeeds the message goes
+// to the first fallback that is available.
+func (m *multi) Notify(msg Message) error {
+	if msg.Urgency == "" {
+		msg.Urgency = Normal
+	}
+	var errs []error
+	delivered := false
+	for _, n := range m.notifiers {
+		if err := n.Notify(msg); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", n.Name(), err))
+			continue
+		}
+		delivered = true
+	}
+	if delivered {
+		for _, err := range errs {
+			log.Printf("Notification error: %v", err)
+		}
+		return nil
+	}
+	for _, n := range m.fallbacks {
+		err := n.Notify(msg)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", n.Name(), err))
+	}
+	return errors.Join(errs...)
+}
+
+// NotifyActions shows the actions on the first notifier that supports
+// them and delivers the plain message to the others.
+func (m *multi) NotifyActions(msg Message) (<-chan string, error) {
+	if msg.Urgency == "" {
+		msg.Urgency = Normal
+	}
+	var actions <-chan string
+	var rest []Notifier
+	for _, n := range m.notifiers {
+		if a, ok := n.(Actioner); ok && actions == nil {
+			if ch, err := a.NotifyActions(msg); err == nil {
+				actions = ch
+				continue
+			}
+		}
+		rest = append(rest, n)
+	}
+	if actions == nil {
+		return nil, m.Notify(msg)
+	}
+	plain := &multi{notifiers: rest}
+	if err := plain.Notify(msg); err != nil && len(rest) > 0 {
+		log.Printf("Notification error: %v", err)
+	}
+	return actions, nil
+}
+
+// lookPath finds a binary or reports the notifier as unavailable.
+func lookPath(file string) (string, error) {
+	path, err := exec.LookPath(file)
+	if err != nil {
+		return "", fmt.Errorf("%w: %s not found", ErrUnavailable, file)
+	}
+	return path, nil
+}
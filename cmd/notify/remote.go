@@ -0,0 +1,137 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	netsmtp "net/smtp"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/benpsk/todo/config"
+)
+
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// logFile appends every message to a file, ~/.todo-notifications.log by
+// default.
+type logFile struct {
+	name string
+	path string
+}
+
+func newLogFile(name, path string) *logFile {
+	if path == "" {
+		homeDir, _ := os.UserHomeDir()
+		path = filepath.Join(homeDir, ".todo-notifications.log")
+	}
+	return &logFile{name: name, path: path}
+}
+
+func (n *logFile) Name() string { return n.name }
+
+func (n *logFile) Notify(msg Message) error {
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	body := strings.ReplaceAll(msg.Body, "\n", " | ")
+	_, err = fmt.Fprintf(f, "%s [%s] %s: %s\n", time.Now().Format("2006-01-02 15:04:05"), msg.Urgency, msg.Title, body)
+	return err
+}
+
+// webhook posts the message as json.
+type webhook struct {
+	name  string
+	url   string
+	token string
+}
+
+func (n *webhook) Name() string { return n.name }
+
+func (n *webhook) Notify(msg Message) error {
+	data, err := json.Marshal(map[string]string{
+		"title":   msg.Title,
+		"body":    msg.Body,
+		"urgency": msg.Urgency,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return send(req, n.token)
+}
+
+// ntfy publishes to an ntfy.sh compatible topic url.
+type ntfy struct {
+	name  string
+	url   string
+	token string
+}
+
+func (n *ntfy) Name() string { return n.name }
+
+func (n *ntfy) Notify(msg Message) error {
+	req, err := http.NewRequest(http.MethodPost, n.url, strings.NewReader(msg.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Title", msg.Title)
+	req.Header.Set("Priority", map[string]string{Low: "2", Normal: "3", Critical: "5"}[msg.Urgency])
+	req.Header.Set("Tags", "memo")
+	return send(req, n.token)
+}
+
+func send(req *http.Request, token string) error {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode >= 300 {
+		return fmt.Errorf("%s: %s", req.URL, res.Status)
+	}
+	return nil
+}
+
+// smtp sends the message as a plain text email.
+type smtp struct {
+	name string
+	cfg  config.Notifier
+}
+
+func (n *smtp) Name() string { return n.name }
+
+func (n *smtp) Notify(msg Message) error {
+	port := n.cfg.Port
+	if port == 0 {
+		port = 587
+	}
+	from := n.cfg.From
+	if from == "" {
+		from = n.cfg.Username
+	}
+	var auth netsmtp.Auth
+	if n.cfg.Username != "" {
+		auth = netsmtp.PlainAuth("", n.cfg.Username, n.cfg.Password, n.cfg.Host)
+	}
+	subject := msg.Title
+	if msg.Urgency == Critical {
+		subject = "[urgent] " + subject
+	}
+	body := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n",
+		from, strings.Join(n.cfg.To, ", "), subject, msg.Body)
+	addr := fmt.Sprintf("%s:%d", n.cfg.Host, port)
+	return netsmtp.SendMail(addr, auth, from, n.cfg.To, []byte(body))
+}
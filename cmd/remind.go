@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/db"
+)
+
+type reminder struct {
+	id      int
+	at      *string // when it fires, nil when relative to a missing due
+	before  *int    // seconds before due
+	firedAt *time.Time
+}
+
+func (app *App) remind() {
+	fs := flag.NewFlagSet("remind", flag.ExitOnError)
+	at := fs.String("at", "", "Time of the reminder (e.g., \"tomorrow 09:00\", \"fri 18:00\")")
+	before := fs.String("before", "", "Remind relative to the due date (e.g., 30m, 1h)")
+	clearAll := fs.Bool("clear", false, "Remove the reminders that have not fired")
+	flagArgs, args := service.SplitArgs(fs, os.Args[2:])
+	fs.Parse(flagArgs)
+	if len(args) != 1 {
+		fmt.Println("usage: todo remind <id> [--at=TIME] [--before=DURATION] [--clear]")
+		os.Exit(1)
+	}
+	id := service.ValidateIds(args)[0]
+	todoText(app.db.QueryRow, id)
+
+	if *clearAll {
+		if _, err := app.db.Exec("delete from reminders where todo_id = ? and fired_at is null", id); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Println("Reminders cleared for id:", id)
+		return
+	}
+	if *at != "" {
+		t, err := service.ParseTime(*at)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if _, err := app.db.Exec(`
+      insert into reminders(todo_id, remind_at) values(?,?)
+    `, id, t.Format(db.TimeFormat)); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Reminder set for id %d at %s\n", id, t.Format("2006-01-02 15:04"))
+	}
+	if *before != "" {
+		d, err := time.ParseDuration(*before)
+		if err != nil || d < 0 {
+			fmt.Fprintf(os.Stderr, "Invalid duration %v (e.g., 30m)\n", *before)
+			os.Exit(1)
+		}
+		if _, err := app.db.Exec(`
+      insert into reminders(todo_id, before) values(?,?)
+    `, id, int(d.Seconds())); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("Reminder set for id %d %s before due\n", id, service.FormatDuration(d))
+	}
+	if *at == "" && *before == "" {
+		reminders, err := app.reminders(id)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printReminders(reminders)
+	}
+}
+
+func (app *App) reminders(todoID int) ([]reminder, error) {
+	rows, err := app.db.Query(`
+    select r.id, `+db.RemindAt+`, r.before, r.fired_at
+    from reminders r join todos t on t.id = r.todo_id
+    where r.todo_id = ?
+    order by r.id
+  `, todoID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []reminder
+	for rows.Next() {
+		var r reminder
+		if err := rows.Scan(&r.id, &r.at, &r.before, &r.firedAt); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, r)
+	}
+	return reminders, rows.Err()
+}
+
+func printReminders(reminders []reminder) {
+	if len(reminders) == 0 {
+		fmt.Println("No reminders")
+		return
+	}
+	for _, r := range reminders {
+		at := "(no due date)"
+		if r.at != nil {
+			at = *r.at
+		}
+		if r.before != nil {
+			at += fmt.Sprintf(" (%s before due)", service.FormatDuration(time.Duration(*r.before)*time.Second))
+		}
+		state := "pending"
+		if r.firedAt != nil {
+			state = "fired " + db.Local(*r.firedAt).Format("2006-01-02 15:04")
+		}
+		fmt.Printf("  #%-4d %-40s %s\n", r.id, at, state)
+	}
+}
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+type entry struct {
+	todoID   int
+	text     string
+	tag      *string
+	project  *string
+	person   *string
+	duration time.Duration
+}
+
+func (app *App) report() {
+	if len(os.Args) < 3 {
+		reportUsage()
+	}
+	switch os.Args[2] {
+	case "time":
+		app.reportTime()
+	case "estimate":
+		app.reportEstimate()
+	default:
+		reportUsage()
+	}
+}
+
+func reportUsage() {
+	fmt.Println("usage: todo report time|estimate [--by=tag|project|task|person] [--range=RANGE]")
+	os.Exit(1)
+}
+
+func (app *App) reportTime() {
+	fs := flag.NewFlagSet("report time", flag.ExitOnError)
+	by := fs.String("by", "tag", "Group by tag, project, task or person")
+	rangeName := fs.String("range", "this-week", "today, yesterday, this-week, last-week, this-month, last-month or all")
+	fs.Parse(os.Args[3:])
+
+	from, to, err := service.Range(*rangeName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	entries, err := app.timeEntries(from, to)
+	if err != nil {
+		log.Fatal(err)
+	}
+	totals := map[string]time.Duration{}
+	var total time.Duration
+	for _, e := range entries {
+		for _, key := range groupKeys(e, *by) {
+			totals[key] += e.duration
+		}
+		total += e.duration
+	}
+	keys := make([]string, 0, len(totals))
+	for k := range totals {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return totals[keys[i]] > totals[keys[j]] })
+
+	fmt.Println("=======================================")
+	fmt.Printf("     ⏱  Time by %s (%s)\n", *by, *rangeName)
+	fmt.Println("=======================================")
+	for _, k := range keys {
+		fmt.Printf("%-28s | %s\n", k, service.FormatDuration(totals[k]))
+	}
+	fmt.Println("---------------------------------------")
+	fmt.Printf("%-28s | %s\n", "total", service.FormatDuration(total))
+	fmt.Println("=======================================")
+}
+
+// timeEntries returns the time logged in [from, to). A running timer
+// counts up to now.
+func (app *App) timeEntries(from, to time.Time) ([]entry, error) {
+	rows, err := app.db.Query(`
+    select e.todo_id, t.text, t.tag, t.project, e.person, e.started_at, e.ended_at, e.duration
+    from time_entries e join todos t on t.id = e.todo_id
+    where e.started_at >= ? and e.started_at < ?
+  `, from.Format(db.TimeFormat), to.Format(db.TimeFormat))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []entry
+	for rows.Next() {
+		var e entry
+		var started time.Time
+		var ended *time.Time
+		var seconds int
+		if err := rows.Scan(&e.todoID, &e.text, &e.tag, &e.project, &e.person,
+			&started, &ended, &seconds); err != nil {
+			return nil, err
+		}
+		e.duration = time.Duration(seconds) * time.Second
+		if ended == nil {
+			e.duration = time.Since(db.Local(started))
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+func groupKeys(e entry, by string) []string {
+	switch by {
+	case "task":
+		return []string{fmt.Sprintf("%d %s", e.todoID, e.text)}
+	case "project":
+		if e.project != nil && *e.project != "" {
+			return []string{*e.project}
+		}
+	case "person":
+		if e.person != nil && *e.person != "" {
+			return []string{*e.person}
+		}
+	default:
+		if e.tag != nil && *e.tag != "" {
+			tags := strings.Split(*e.tag, ",")
+			for i := range tags {
+				tags[i] = strings.TrimSpace(tags[i])
+			}
+			return tags
+		}
+	}
+	return []string{"(none)"}
+}
+
+// reportEstimate compares estimates with the time logged on finished
+// tasks. When grouped by person, each person is accounted the share of
+// the estimate matching their share of the logged time.
+func (app *App) reportEstimate() {
+	fs := flag.NewFlagSet("report estimate", flag.ExitOnError)
+	by := fs.String("by", "tag", "Group by tag, project, task or person")
+	rangeName := fs.String("range", "all", "today, yesterday, this-week, last-week, this-month, last-month or all")
+	fs.Parse(os.Args[3:])
+
+	from, to, err := service.Range(*rangeName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	entries, err := app.timeEntries(from, to)
+	if err != nil {
+		log.Fatal(err)
+	}
+	estimates, err := app.finishedEstimates()
+	if err != nil {
+		log.Fatal(err)
+	}
+	logged := map[int]time.Duration{}
+	for _, e := range entries {
+		logged[e.todoID] += e.duration
+	}
+	type total struct{ estimate, actual time.Duration }
+	totals := map[string]*total{}
+	var sum total
+	for _, e := range entries {
+		estimate, exists := estimates[e.todoID]
+		if !exists || logged[e.todoID] == 0 {
+			continue
+		}
+		share := time.Duration(float64(estimate) * float64(e.duration) / float64(logged[e.todoID]))
+		for _, key := range groupKeys(e, *by) {
+			if totals[key] == nil {
+				totals[key] = &total{}
+			}
+			totals[key].estimate += share
+			totals[key].actual += e.duration
+		}
+		sum.estimate += share
+		sum.actual += e.duration
+	}
+	keys := make([]string, 0, len(totals))
+	for k := range totals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	accuracy := func(t total) string {
+		if t.estimate == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.0f%%", float64(t.actual)/float64(t.estimate)*100)
+	}
+	fmt.Println("=======================================")
+	fmt.Printf("     🎯  Estimate by %s (%s)\n", *by, *rangeName)
+	fmt.Println("=======================================")
+	fmt.Printf("%-20s | %-8s | %-8s | %s\n", *by, "estimate", "actual", "actual/estimate")
+	for _, k := range keys {
+		t := *totals[k]
+		fmt.Printf("%-20s | %-8s | %-8s | %s\n", k, service.FormatDuration(t.estimate),
+			service.FormatDuration(t.actual), accuracy(t))
+	}
+	fmt.Println("---------------------------------------")
+	fmt.Printf("%-20s | %-8s | %-8s | %s\n", "total", service.FormatDuration(sum.estimate),
+		service.FormatDuration(sum.actual), accuracy(sum))
+	fmt.Println("=======================================")
+}
+
+// finishedEstimates returns the estimate of every estimated task in a
+// terminal status, by id.
+func (app *App) finishedEstimates() (map[int]time.Duration, error) {
+	terminal := config.Get().TerminalStatuses()
+	args := make([]interface{}, len(terminal))
+	for i, v := range terminal {
+		args[i] = v
+	}
+	rows, err := app.db.Query(`
+    select id, estimate
+    from todos
+    where estimate > 0 and status in (`+db.Placeholders(len(terminal))+`)
+  `, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	estimates := map[int]time.Duration{}
+	for rows.Next() {
+		var id, seconds int
+		if err := rows.Scan(&id, &seconds); err != nil {
+			return nil, err
+		}
+		estimates[id] = time.Duration(seconds) * time.Second
+	}
+	return estimates, rows.Err()
+}
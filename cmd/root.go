@@ -7,6 +7,7 @@ import (
 
 	"github.com/benpsk/todo/cmd/daemon"
 	"github.com/benpsk/todo/cmd/ui"
+	"github.com/benpsk/todo/config"
 	"github.com/benpsk/todo/db"
 )
 
@@ -26,6 +27,9 @@ func Execute() {
 		ui.Usage()
 		return
 	}
+	if _, err := config.Load(config.Path()); err != nil {
+		log.Fatal(err)
+	}
 	db, err := db.Connect(dbPath)
 	if err != nil {
 		log.Fatal(err)
@@ -40,14 +44,48 @@ func Execute() {
 		app.add()
 	case "list", "ls":
 		app.list()
+	case "show":
+		app.show()
+	case "remind":
+		app.remind()
+	case "snooze":
+		app.snooze()
 	case "delete":
 		app.delete()
+	case "archive":
+		app.archive()
 	case "update":
 		app.update()
+	case "start":
+		app.start()
+	case "stop":
+		app.stop()
+	case "log":
+		app.logTime()
+	case "report":
+		app.report()
+	case "import":
+		app.importTasks()
+	case "export":
+		app.export()
+	case "backup":
+		app.backup()
+	case "restore":
+		app.restore()
+	case "serve":
+		app.serve()
+	case "tui":
+		app.tui()
+	case "history":
+		app.history()
+	case "undo":
+		app.undo()
 	case "--help", "-h":
 		ui.Usage()
 	case "daemon":
 		d.Handle()
+	case "dnd":
+		d.DND()
 	default:
 		ui.Usage()
 	}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/benpsk/todo/cmd/api"
+	"github.com/benpsk/todo/cmd/web"
+	"github.com/benpsk/todo/config"
+)
+
+func (app *App) serve() {
+	cfg := config.Get().API
+	if cfg.Addr == "" {
+		cfg.Addr = "127.0.0.1:8080"
+	}
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", cfg.Addr, "Address to listen on")
+	token := fs.String("token", cfg.Token, "Bearer token required on every request, empty for none")
+	fs.Parse(os.Args[2:])
+	if fs.NArg() > 0 {
+		fmt.Println("usage: todo serve [--addr=HOST:PORT] [--token=TOKEN]")
+		os.Exit(1)
+	}
+
+	if err := api.CheckAddr(*addr, *token); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", api.New(app.db, *token))
+	mux.Handle("/ui/", web.New(app.db, *token))
+	server := &http.Server{Handler: mux}
+	done := make(chan struct{})
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+		<-c
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+		close(done)
+	}()
+
+	fmt.Printf("Serving the API on http://%s, spec at /openapi.json, web UI at /ui/\n", *addr)
+	if err := server.Serve(l); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-done
+}
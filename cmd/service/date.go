@@ -0,0 +1,108 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// ParseDay parses today, yesterday, tomorrow, a weekday (e.g. "mon") or
+// a date (e.g. "2025-08-20") into midnight of that day. A weekday means
+// the last occurrence when past is set and the next one otherwise.
+func ParseDay(day string, past bool) (time.Time, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	day = strings.ToLower(day)
+	switch day {
+	case "today", "":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+	if weekday, exists := weekdays[day]; exists {
+		diff := int(weekday - today.Weekday())
+		if past {
+			return today.AddDate(0, 0, -((-diff + 7) % 7)), nil
+		}
+		if diff <= 0 {
+			diff += 7 // Next occurrence is in a week if it's the same day
+		}
+		return today.AddDate(0, 0, diff), nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", day, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", day)
+	}
+	return t, nil
+}
+
+// ParseTime parses a day and an optional time of day such as
+// "tomorrow 09:00", "fri 18:00", "2025-08-20 09:00" or "18:00". A day
+// alone means 09:00, a time alone means its next occurrence.
+func ParseTime(value string) (time.Time, error) {
+	day, clock, found := strings.Cut(strings.TrimSpace(value), " ")
+	if !found && strings.Contains(day, ":") {
+		day, clock = "", day
+	}
+	if clock == "" {
+		clock = "09:00"
+	}
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q", value)
+	}
+	date, err := ParseDay(day, false)
+	if err != nil {
+		return time.Time{}, err
+	}
+	at := date.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+	if day == "" && at.Before(time.Now()) {
+		at = at.AddDate(0, 0, 1)
+	}
+	return at, nil
+}
+
+// Range parses a report range such as this-week into [from, to).
+func Range(name string) (time.Time, time.Time, error) {
+	today, _ := ParseDay("today", true)
+	// weeks start on monday
+	monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	month := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.Local)
+	switch name {
+	case "today":
+		return today, today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today, nil
+	case "this-week", "":
+		return monday, monday.AddDate(0, 0, 7), nil
+	case "last-week":
+		return monday.AddDate(0, 0, -7), monday, nil
+	case "this-month":
+		return month, month.AddDate(0, 1, 0), nil
+	case "last-month":
+		return month.AddDate(0, -1, 0), month, nil
+	case "all":
+		return time.Time{}, today.AddDate(100, 0, 0), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid range %q", name)
+}
+
+// FormatDuration prints a duration rounded to minutes, e.g. 1h30m.
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	h, m := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%dm", m)
+	case m == 0:
+		return fmt.Sprintf("%dh", h)
+	}
+	return fmt.Sprintf("%dh%dm", h, m)
+}
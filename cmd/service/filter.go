@@ -0,0 +1,113 @@
+package service
+
+import (
+	"time"
+
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+// LoggedQuery sums the time logged on the todo of the current row.
+const LoggedQuery = "(SELECT coalesce(sum(duration), 0) FROM time_entries WHERE todo_id = todos.id)"
+
+// Filter selects todos, as the list flags do for list, archive and
+// export and the query parameters for the API.
+type Filter struct {
+	Status     string
+	Priority   string
+	PriorityOp string // =, !=, >, >=, <, <=
+	Due        *string
+	Tag        string
+	Project    string
+	Find       string
+	Created    string
+	Fits       int // seconds
+	Archived   bool
+}
+
+func (f *Filter) GetStatus() string    { return f.Status }
+func (f *Filter) SetStatus(s string)   { f.Status = s }
+func (f *Filter) GetPriority() string  { return f.Priority }
+func (f *Filter) SetPriority(p string) { f.Priority = p }
+func (f *Filter) GetDue() *string      { return f.Due }
+func (f *Filter) SetDue(d *string)     { f.Due = d }
+
+// Where builds the where clause of the filter, archived_at is left to
+// the caller.
+func (f *Filter) Where() (string, []interface{}) {
+	query := " WHERE 1=1"  // Always true to make conditional appending easier
+	var args []interface{} // To hold arguments for the query
+
+	if f.Status != "" {
+		query += " AND status=?"
+		args = append(args, f.Status)
+	}
+	if f.Priority != "" {
+		op := f.PriorityOp
+		if op == "" {
+			op = "="
+		}
+		query += " AND priority" + op + "?"
+		args = append(args, f.Priority)
+	}
+	if f.Due != nil {
+		q, argv := DateQuery(*f.Due, "due", "<=")
+		query += q
+		for _, v := range argv {
+			args = append(args, v)
+		}
+	}
+	if f.Tag != "" {
+		query += " AND tag like ?"
+		args = append(args, "%"+f.Tag+"%")
+	}
+	if f.Project != "" {
+		query += " AND project=?"
+		args = append(args, f.Project)
+	}
+	if f.Find != "" {
+		query += " AND text LIKE ?"
+		args = append(args, "%"+f.Find+"%") // Adding wildcard for LIKE search
+	}
+	if f.Fits > 0 {
+		terminal := config.Get().TerminalStatuses()
+		query += " AND estimate - " + LoggedQuery + " BETWEEN 1 AND ?"
+		query += " AND status NOT IN (" + db.Placeholders(len(terminal)) + ")"
+		args = append(args, f.Fits)
+		for _, v := range terminal {
+			args = append(args, v)
+		}
+	}
+	if f.Created != "" {
+		q, argv := DateQuery(f.Created, "created_at", "=")
+		query += q
+		for _, v := range argv {
+			args = append(args, v)
+		}
+	}
+	return query, args
+}
+
+// IsValidCreated checks the created date, turning a weekday into the
+// date of its last occurrence.
+func IsValidCreated(f *Filter) bool {
+	if f.Created == "" {
+		return true
+	}
+	daysDiff, isSuccess := IsValidateDate(f.Created)
+	if !isSuccess {
+		return false
+	}
+	// default no value change
+	if daysDiff == 99 {
+		return true
+	}
+	// Calculate days to previous weekday
+	daysToLast := (7 - daysDiff) % 7
+	if daysToLast == 0 {
+		daysToLast = 7 // Previous occurrence was a week ago if it's the same day
+	}
+	weekdayDate := time.Now().AddDate(0, 0, -daysToLast)
+	f.Created = weekdayDate.Format("2006-01-02")
+	return true
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFilterWhere(t *testing.T) {
+	due := "2025-08-06"
+	month := "2025-08"
+	tests := []struct {
+		name  string
+		f     Filter
+		query string
+		args  []interface{}
+	}{
+		{"empty", Filter{}, " WHERE 1=1", nil},
+		{"status", Filter{Status: "3"}, " WHERE 1=1 AND status=?", []interface{}{"3"}},
+		{"priority", Filter{Priority: "2"}, " WHERE 1=1 AND priority=?", []interface{}{"2"}},
+		{"priority op", Filter{Priority: "3", PriorityOp: ">="}, " WHERE 1=1 AND priority>=?", []interface{}{"3"}},
+		{"due day", Filter{Due: &due},
+			" WHERE 1=1 AND strftime('%Y-%m-%d', due)<=?", []interface{}{"2025-08-06"}},
+		{"due month", Filter{Due: &month},
+			" WHERE 1=1 AND strftime('%m', due)<=? and strftime('%Y', due)<=?", []interface{}{"08", "2025"}},
+		{"tag", Filter{Tag: "home"}, " WHERE 1=1 AND tag like ?", []interface{}{"%home%"}},
+		{"project", Filter{Project: "web"}, " WHERE 1=1 AND project=?", []interface{}{"web"}},
+		{"find", Filter{Find: "milk"}, " WHERE 1=1 AND text LIKE ?", []interface{}{"%milk%"}},
+		{"fits", Filter{Fits: 3600},
+			" WHERE 1=1 AND estimate - " + LoggedQuery + " BETWEEN 1 AND ? AND status NOT IN (?,?)",
+			[]interface{}{3600, 3, 7}},
+		{"created", Filter{Created: "2025"}, " WHERE 1=1 and strftime('%Y', created_at)=?", []interface{}{"2025"}},
+		{"combined", Filter{Status: "1", Tag: "home", Project: "web"},
+			" WHERE 1=1 AND status=? AND tag like ? AND project=?", []interface{}{"1", "%home%", "web"}},
+		{"archived is left to the caller", Filter{Archived: true}, " WHERE 1=1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args := tt.f.Where()
+			if query != tt.query {
+				t.Errorf("query = %q, want %q", query, tt.query)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %#v, want %#v", args, tt.args)
+			}
+		})
+	}
+}
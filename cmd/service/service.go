@@ -4,10 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"slices"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/benpsk/todo/config"
 )
 
 type Flagger interface {
@@ -20,28 +21,32 @@ type Flagger interface {
 }
 
 func Validate(cmd Flagger) bool {
-	var msg []string
-	statuses := map[string]string{
-		"pending":    "1",
-		"processing": "2",
-		"done":       "3",
+	msg := Check(cmd)
+	if len(msg) > 0 {
+		fmt.Println("Errors:")
+		for _, v := range msg {
+			fmt.Fprintf(os.Stderr, "    %v\n", v)
+		}
+		return false
 	}
+	return true
+}
+
+// Check turns status and priority names into their stored values and
+// resolves the due date, returning what is invalid.
+func Check(cmd Flagger) []string {
+	var msg []string
 	if cmd.GetStatus() != "" {
-		if validStatus, exists := statuses[cmd.GetStatus()]; exists {
-			cmd.SetStatus(validStatus)
-		} else if !slices.Contains([]string{"1", "2", "3"}, cmd.GetStatus()) {
+		if status, exists := config.Get().Status(cmd.GetStatus()); exists {
+			cmd.SetStatus(strconv.Itoa(status.Value))
+		} else {
 			msg = append(msg, fmt.Sprintf("Invalid status %v", cmd.GetStatus()))
 		}
 	}
-	priorities := map[string]string{
-		"low":    "1",
-		"medium": "2",
-		"high":   "3",
-	}
 	if cmd.GetPriority() != "" {
-		if validPriority, exists := priorities[cmd.GetPriority()]; exists {
-			cmd.SetPriority(validPriority)
-		} else if !slices.Contains([]string{"1", "2", "3"}, cmd.GetPriority()) {
+		if priority, exists := config.Get().Priority(cmd.GetPriority()); exists {
+			cmd.SetPriority(strconv.Itoa(priority.Weight))
+		} else {
 			msg = append(msg, fmt.Sprintf("Invalid priority %v", cmd.GetPriority()))
 		}
 	}
@@ -52,14 +57,7 @@ func Validate(cmd Flagger) bool {
 	} else {
 		cmd.SetDue(nil)
 	}
-	if len(msg) > 0 {
-		fmt.Println("Errors:")
-		for _, v := range msg {
-			fmt.Fprintf(os.Stderr, "    %v\n", v)
-		}
-		return false
-	}
-	return true
+	return msg
 }
 
 func isValidDueDate(cmd Flagger) bool {
@@ -129,6 +127,30 @@ func DateQuery(date string, col string, op string) (string, []string) {
 	return query, args
 }
 
+// SplitOp splits a leading comparison operator off a filter value, e.g.
+// ">=high" becomes ">=" and "high". The operator defaults to "=".
+func SplitOp(value string) (string, string) {
+	for _, op := range []string{">=", "<=", "!=", ">", "<", "="} {
+		if strings.HasPrefix(value, op) {
+			return op, strings.TrimPrefix(value, op)
+		}
+	}
+	return "=", value
+}
+
+// ParseEstimate parses an effort such as 1h30m into seconds. An empty
+// estimate is 0.
+func ParseEstimate(estimate string) (int, error) {
+	if estimate == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(estimate)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("Invalid estimate %v (e.g., 2h, 45m)", estimate)
+	}
+	return int(d.Seconds()), nil
+}
+
 func ValidateIds(ids []string) []int {
 	idList := make([]int, 0, len(ids))
 	for _, arg := range ids {
@@ -147,7 +169,12 @@ type ParseRes struct {
 	Priority    *string
 	Due         *string
 	Tag         *string
+	Project     *string
 	Created     *string
+	Find        *string
+	Estimate    *string
+	Fits        *string
+	Archived    *bool
 	FlagArgs    []string
 	NonFlagArgs []string
 }
@@ -158,26 +185,44 @@ func Parse(fs *flag.FlagSet, cmd string) *ParseRes {
 		priority string
 		due      string
 		tag      string
+		project  string
 		created  string
+		find     string
+		estimate string
+		fits     string
+		archived string
 	}{
 		status:   "Status of the task (e.g., done, pending)",
 		priority: "Priority of the task (e.g., high, low)",
 		due:      "Due date of the task (e.g., 2025-08-06)",
 		tag:      "Tag of the task (e.g., Project 01)",
+		project:  "Project of the task (e.g., website)",
 		created:  "created date of the task (eg. 2025-08-01)",
+		find:     "Search for keyword in task",
+		estimate: "Estimated effort of the task (e.g., 2h, 45m)",
+		fits:     "Only tasks whose remaining estimate fits (e.g., 45m)",
+		archived: "Only archived tasks",
 	}
 	status := fs.String("status", "", guide.status)
 	priority := fs.String("priority", "", guide.priority)
 	due := fs.String("due", "", guide.due)
 	tag := fs.String("tag", "", guide.tag)
+	project := fs.String("project", "", guide.project)
 	created := fs.String("created", "", guide.created)
+	find := fs.String("find", "", guide.find)
+	estimate := fs.String("estimate", "", guide.estimate)
+	fits := fs.String("fits", "", guide.fits)
+	archived := fs.Bool("archived", false, guide.archived)
 
 	// Shortcuts
 	fs.StringVar(status, "s", *status, guide.status)
 	fs.StringVar(priority, "p", *priority, guide.priority)
 	fs.StringVar(due, "d", *due, guide.due)
 	fs.StringVar(tag, "t", *tag, guide.tag)
+	fs.StringVar(project, "P", *project, guide.project)
 	fs.StringVar(created, "c", *created, guide.created)
+	fs.StringVar(find, "f", *find, guide.find)
+	fs.StringVar(estimate, "e", *estimate, guide.estimate)
 
 	// Custom usage function to include all flags
 	fs.Usage = func() {
@@ -193,18 +238,57 @@ func Parse(fs *flag.FlagSet, cmd string) *ParseRes {
 				fmt.Fprintf(os.Stderr, "  -d, --due\t\t%s\n", f.Usage)
 			case "tag":
 				fmt.Fprintf(os.Stderr, "  -t, --tag\t\t%s\n", f.Usage)
+			case "project":
+				fmt.Fprintf(os.Stderr, "  -P, --project\t\t%s\n", f.Usage)
 			case "created":
 				fmt.Fprintf(os.Stderr, "  -c, --created\t\t%s\n", f.Usage)
+			case "find":
+				fmt.Fprintf(os.Stderr, "  -f, --find\t\t%s\n", f.Usage)
+			case "estimate":
+				fmt.Fprintf(os.Stderr, "  -e, --estimate\t%s\n", f.Usage)
+			case "fits":
+				fmt.Fprintf(os.Stderr, "      --fits\t\t%s\n", f.Usage)
+			case "archived":
+				fmt.Fprintf(os.Stderr, "      --archived\t%s\n", f.Usage)
+			default:
+				// flags the command added itself
+				if len(f.Name) > 1 {
+					fmt.Fprintf(os.Stderr, "      --%s\t\t%s\n", f.Name, f.Usage)
+				}
 			}
 		})
 	}
+	flagArgs, nonFlagArgs := SplitArgs(fs, os.Args[2:])
+	fs.Parse(flagArgs)
+
+	return &ParseRes{
+		Status:      status,
+		Priority:    priority,
+		Due:         due,
+		Tag:         tag,
+		Project:     project,
+		Created:     created,
+		Find:        find,
+		Estimate:    estimate,
+		Fits:        fits,
+		Archived:    archived,
+		FlagArgs:    flagArgs,
+		NonFlagArgs: nonFlagArgs,
+	}
+}
+
+// SplitArgs separates flags and their values from positional arguments so
+// flags may follow them, e.g. todo log 5 1h --date=yesterday.
+func SplitArgs(fs *flag.FlagSet, args []string) ([]string, []string) {
 	var flagArgs, nonFlagArgs []string
-	args := os.Args[2:]
 	for i := 0; i < len(args); i++ {
-		arg := args[i]
-		if strings.HasPrefix(arg, "-") || strings.HasPrefix(arg, "--") {
+		arg := comparison(args[i])
+		if isFlag(arg) {
 			flagArgs = append(flagArgs, arg)
-			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") && !strings.HasPrefix(args[i+1], "--") && !strings.Contains(arg, "=") {
+			if isBoolFlag(fs, arg) {
+				continue
+			}
+			if i+1 < len(args) && !isFlag(args[i+1]) && !strings.Contains(arg, "=") {
 				flagArgs = append(flagArgs, args[i+1])
 				i++
 			}
@@ -212,15 +296,38 @@ func Parse(fs *flag.FlagSet, cmd string) *ParseRes {
 			nonFlagArgs = append(nonFlagArgs, arg)
 		}
 	}
-	fs.Parse(flagArgs)
+	return flagArgs, nonFlagArgs
+}
 
-	return &ParseRes{
-		Status:      status,
-		Priority:    priority,
-		Due:         due,
-		Tag:         tag,
-		Created:     created,
-		FlagArgs:    flagArgs,
-		NonFlagArgs: nonFlagArgs,
+// isFlag reports whether arg is a flag. A lone "-" is a value, such as
+// the alias of the none priority in "-p -".
+func isFlag(arg string) bool {
+	return strings.HasPrefix(arg, "-") && arg != "-"
+}
+
+// isBoolFlag reports whether arg is a bool flag, which never takes the
+// next argument as its value.
+func isBoolFlag(fs *flag.FlagSet, arg string) bool {
+	f := fs.Lookup(strings.TrimLeft(arg, "-"))
+	if f == nil {
+		return false
+	}
+	b, ok := f.Value.(interface{ IsBoolFlag() bool })
+	return ok && b.IsBoolFlag()
+}
+
+// comparison rewrites "--priority>=high" as "--priority=>=high" so the
+// operator ends up in the flag value.
+func comparison(arg string) string {
+	name := strings.TrimLeft(arg, "-")
+	if name == arg {
+		return arg
+	}
+	for _, flagName := range []string{"priority", "p"} {
+		rest, found := strings.CutPrefix(name, flagName)
+		if found && (strings.HasPrefix(rest, ">") || strings.HasPrefix(rest, "<") || strings.HasPrefix(rest, "!=")) {
+			return arg[:len(arg)-len(name)] + flagName + "=" + rest
+		}
 	}
+	return arg
 }
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.String("priority", "", "")
+	fs.String("p", "", "")
+	fs.String("date", "", "")
+	fs.Bool("archived", false, "")
+	tests := []struct {
+		args    string
+		flags   []string
+		nonFlag []string
+	}{
+		{"", nil, nil},
+		{"buy milk", nil, []string{"buy", "milk"}},
+		{"5 1h --date=yesterday", []string{"--date=yesterday"}, []string{"5", "1h"}},
+		{"5 --date yesterday 1h", []string{"--date", "yesterday"}, []string{"5", "1h"}},
+		{"--archived 3", []string{"--archived"}, []string{"3"}},
+		{"-p - text", []string{"-p", "-"}, []string{"text"}},
+		{"--priority>=high", []string{"--priority=>=high"}, nil},
+		{"-p!=low task", []string{"-p=!=low"}, []string{"task"}},
+		{"--date --archived", []string{"--date", "--archived"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			flags, nonFlag := SplitArgs(fs, strings.Fields(tt.args))
+			if !reflect.DeepEqual(flags, tt.flags) {
+				t.Errorf("flags = %q, want %q", flags, tt.flags)
+			}
+			if !reflect.DeepEqual(nonFlag, tt.nonFlag) {
+				t.Errorf("non-flag args = %q, want %q", nonFlag, tt.nonFlag)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/config"
+)
+
+func (app *App) show() {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	fs.Parse(os.Args[2:])
+	if fs.NArg() != 1 {
+		fmt.Println("usage: todo show <id>")
+		os.Exit(1)
+	}
+	id := service.ValidateIds(fs.Args())[0]
+
+	var t todo
+	var project *string
+	var updatedAt time.Time
+	var archivedAt *time.Time
+	var recur string
+	err := app.db.QueryRow(`
+    SELECT id, text, priority, status, due, tag, project, created_at, updated_at, archived_at,
+      coalesce(estimate, 0), `+service.LoggedQuery+`, coalesce(recur, '')
+    FROM todos
+    WHERE id = ?
+  `, id).Scan(&t.id, &t.text, &t.priority, &t.status, &t.due, &t.tag, &project,
+		&t.createdAt, &updatedAt, &archivedAt, &t.estimate, &t.logged, &recur)
+	if errors.Is(err, sql.ErrNoRows) {
+		fmt.Fprintf(os.Stderr, "No todo with id %d\n", id)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	status := t.status
+	if s, exists := config.Get().Status(t.status); exists {
+		status = s.Name
+	}
+	priority := strconv.Itoa(t.priority)
+	if p, exists := config.Get().Priority(priority); exists {
+		priority = p.Name
+	}
+	fmt.Println("=======================================")
+	fmt.Printf("  #%d %s\n", t.id, t.text)
+	fmt.Println("=======================================")
+	fmt.Printf("%-10s %s\n", "status", status)
+	fmt.Printf("%-10s %s\n", "priority", priority)
+	if t.due != nil {
+		fmt.Printf("%-10s %s\n", "due", t.due.Format("2006-01-02 15:04"))
+	}
+	if t.tag != nil && *t.tag != "" {
+		fmt.Printf("%-10s %s\n", "tag", *t.tag)
+	}
+	if project != nil && *project != "" {
+		fmt.Printf("%-10s %s\n", "project", *project)
+	}
+	if recur != "" {
+		fmt.Printf("%-10s %s\n", "recur", recur)
+	}
+	if t.estimate > 0 {
+		fmt.Printf("%-10s %s\n", "estimate", service.FormatDuration(time.Duration(t.estimate)*time.Second))
+	}
+	if t.logged > 0 {
+		fmt.Printf("%-10s %s\n", "logged", service.FormatDuration(time.Duration(t.logged)*time.Second))
+	}
+	fmt.Printf("%-10s %s\n", "created", t.createdAt.Format("2006-01-02 15:04"))
+	fmt.Printf("%-10s %s\n", "updated", updatedAt.Format("2006-01-02 15:04"))
+	if archivedAt != nil {
+		fmt.Printf("%-10s %s\n", "archived", archivedAt.Format("2006-01-02 15:04"))
+	}
+	reminders, err := app.reminders(id)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Reminders:")
+	printReminders(reminders)
+	fmt.Println("=======================================")
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/db"
+)
+
+func (app *App) snooze() {
+	fs := flag.NewFlagSet("snooze", flag.ExitOnError)
+	fs.Parse(os.Args[2:])
+	if fs.NArg() != 2 {
+		fmt.Println("usage: todo snooze <id> <duration>")
+		os.Exit(1)
+	}
+	id := service.ValidateIds(fs.Args()[:1])[0]
+	d, err := time.ParseDuration(fs.Arg(1))
+	if err != nil || d <= 0 {
+		fmt.Fprintf(os.Stderr, "Invalid duration %v (e.g., 10m, 1h)\n", fs.Arg(1))
+		os.Exit(1)
+	}
+	todoText(app.db.QueryRow, id)
+	at, err := db.Snooze(app.db, id, d)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Snoozed id %d until %s\n", id, at.Format("2006-01-02 15:04"))
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/db"
+)
+
+type timer struct {
+	id        int
+	todoID    int
+	text      string
+	startedAt time.Time
+}
+
+// activeTimer returns the running timer, or nil when none is running.
+// A timer on a todo deleted since still shows, so it can be stopped.
+func (app *App) activeTimer() (*timer, error) {
+	return activeTimer(app.db.QueryRow)
+}
+
+func activeTimer(queryRow func(query string, args ...any) *sql.Row) (*timer, error) {
+	var t timer
+	err := queryRow(`
+    select e.id, e.todo_id, coalesce(t.text, 'deleted'), e.started_at
+    from time_entries e left join todos t on t.id = e.todo_id
+    where e.ended_at is null
+  `).Scan(&t.id, &t.todoID, &t.text, &t.startedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.startedAt = db.Local(t.startedAt)
+	return &t, nil
+}
+
+func (app *App) start() {
+	fs := flag.NewFlagSet("start", flag.ExitOnError)
+	fs.Parse(os.Args[2:])
+	if fs.NArg() != 1 {
+		fmt.Println("usage: todo start <id>")
+		os.Exit(1)
+	}
+	id := service.ValidateIds(fs.Args())[0]
+	// the check and the insert are one transaction, and the
+	// time_entries_running index refuses a second running timer anyway
+	tx, err := app.db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tx.Rollback()
+	active, err := activeTimer(tx.QueryRow)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if active != nil {
+		fmt.Fprintf(os.Stderr, "Timer already running for id %d (%s), stop it first: todo stop\n",
+			active.todoID, active.text)
+		os.Exit(1)
+	}
+	text := todoText(tx.QueryRow, id)
+	_, err = tx.Exec(`
+    insert into time_entries(todo_id, started_at, person) values(?,?,?)
+  `, id, time.Now().Format(db.TimeFormat), person())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Started timer for id %d (%s)\n", id, text)
+}
+
+// todoText returns the text of todo id, exiting when there is none.
+func todoText(queryRow func(query string, args ...any) *sql.Row, id int) string {
+	var text string
+	if err := queryRow("select text from todos where id = ?", id).Scan(&text); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			fmt.Fprintf(os.Stderr, "No todo with id %d\n", id)
+			os.Exit(1)
+		}
+		log.Fatal(err)
+	}
+	return text
+}
+
+func (app *App) stop() {
+	active, err := app.activeTimer()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if active == nil {
+		fmt.Println("No timer running")
+		return
+	}
+	now := time.Now()
+	elapsed := now.Sub(active.startedAt)
+	app.logEntry(active.todoID, `
+    update time_entries set ended_at = ?, duration = ? where id = ?
+  `, now.Format(db.TimeFormat), int(elapsed.Seconds()), active.id)
+	fmt.Printf("Stopped timer for id %d (%s): %s\n",
+		active.todoID, active.text, service.FormatDuration(elapsed))
+}
+
+func (app *App) logTime() {
+	fs := flag.NewFlagSet("log", flag.ExitOnError)
+	date := fs.String("date", "today", "Day the time was spent (e.g., yesterday, mon, 2025-08-06)")
+	flagArgs, args := service.SplitArgs(fs, os.Args[2:])
+	fs.Parse(flagArgs)
+	if len(args) != 2 {
+		fmt.Println("usage: todo log <id> <duration> [--date=DATE]")
+		os.Exit(1)
+	}
+	id := service.ValidateIds(args[:1])[0]
+	d, err := time.ParseDuration(args[1])
+	if err != nil || d <= 0 {
+		fmt.Fprintf(os.Stderr, "Invalid duration %v (e.g., 1h30m)\n", args[1])
+		os.Exit(1)
+	}
+	day, err := service.ParseDay(*date, true)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	todoText(app.db.QueryRow, id)
+	// end the entry at the current time of day on the given day
+	now := time.Now()
+	ended := day.Add(now.Sub(time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)))
+	started := ended.Add(-d)
+	app.logEntry(id, `
+    insert into time_entries(todo_id, started_at, ended_at, duration, person) values(?,?,?,?,?)
+  `, id, started.Format(db.TimeFormat), ended.Format(db.TimeFormat), int(d.Seconds()), person())
+	fmt.Printf("Logged %s for id %d on %s\n", service.FormatDuration(d), id, day.Format("2006-01-02"))
+}
+
+// logEntry writes a time entry of todo id and moves the todo's version,
+// which covers the time logged.
+func (app *App) logEntry(id int, query string, args ...any) {
+	tx, err := app.db.Begin()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(query, args...); err != nil {
+		log.Fatal(err)
+	}
+	if err := db.Touch(tx, id); err != nil {
+		log.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// person is who logs the time, the current os user.
+func person() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/benpsk/todo/cmd/tui"
+)
+
+func (app *App) tui() {
+	fs := flag.NewFlagSet("tui", flag.ExitOnError)
+	fs.Parse(os.Args[2:])
+	if fs.NArg() > 0 {
+		fmt.Println("usage: todo tui")
+		os.Exit(1)
+	}
+	if err := tui.Run(app.db); err != nil {
+		log.Fatal(err)
+	}
+}
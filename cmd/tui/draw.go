@@ -0,0 +1,301 @@
+package tui
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/benpsk/todo/cmd/api"
+	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/config"
+)
+
+// colors are the ANSI codes of the config colors.
+var colors = map[string]string{
+	"red": "31", "green": "32", "yellow": "33", "blue": "34",
+	"magenta": "35", "cyan": "36", "white": "37", "gray": "90",
+}
+
+// span is text in a color, "" for the default.
+type span struct {
+	text  string
+	color string
+}
+
+const hints = "j/k move  / filter  space select  V all  s x 1-9 status  +/- priority  " +
+	"e text  d due  t tags  p project  a add  X delete  enter details  q quit"
+
+// textColumn is where the text of a task starts in its row.
+const textColumn = 2 + 5 + 11 + 9 + 17
+
+// sideBySide is the width from which the details are next to the list
+// rather than under it.
+const sideBySide = 100
+
+// rows is how many tasks the list has room for.
+func (a *app) rows() int {
+	rows := a.height - 2
+	if a.detail && a.width < sideBySide {
+		rows /= 2
+	}
+	return max(rows, 1)
+}
+
+func (a *app) draw() {
+	rows := a.rows()
+	if a.cursor < a.top {
+		a.top = a.cursor
+	}
+	if a.cursor >= a.top+rows {
+		a.top = a.cursor - rows + 1
+	}
+	a.top = max(min(a.top, len(a.shown)-rows), 0)
+
+	listWidth, paneWidth := a.width, 0
+	var pane []string
+	if a.detail {
+		if a.width >= sideBySide {
+			paneWidth = a.width * 2 / 5
+			listWidth = a.width - paneWidth - 3
+		} else {
+			paneWidth = a.width
+		}
+		pane = a.details(paneWidth - 1)
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[?25l")
+	line := func(n int, text string) {
+		fmt.Fprintf(&b, "\x1b[%d;1H%s\x1b[0m\x1b[K", n, text)
+	}
+
+	header := fmt.Sprintf(" todo  %d of %d tasks", len(a.shown), len(a.tasks))
+	if n := len(a.selectedShown()); n > 0 {
+		header += fmt.Sprintf("  %d selected", n)
+	}
+	if f := a.filter.String(); f != "" {
+		header += "  filter: " + f
+	}
+	line(1, "\x1b[7m"+fit(header, a.width))
+
+	cursorRow, cursorCol := 0, 0
+	for i := 0; i < rows; i++ {
+		row := ""
+		if n := a.top + i; n < len(a.shown) {
+			t := a.shown[n]
+			editing := a.mode == editing && a.field == "text" && n == a.cursor
+			row = render(a.row(t, editing), listWidth, n == a.cursor)
+			if editing {
+				cursorRow, cursorCol = i+2, min(textColumn+a.input.pos+1, listWidth)
+			}
+		} else {
+			row = strings.Repeat(" ", listWidth)
+		}
+		if paneWidth > 0 && a.width >= sideBySide {
+			row += " \x1b[90m│\x1b[0m " + at(pane, i)
+		}
+		line(i+2, row)
+	}
+	if paneWidth > 0 && a.width < sideBySide {
+		line(rows+2, "\x1b[90m"+strings.Repeat("─", a.width))
+		for i := 0; rows+3+i < a.height; i++ {
+			line(rows+3+i, at(pane, i))
+		}
+	}
+
+	// the last line prompts, confirms, reports or helps, one short of
+	// the width so the terminal does not scroll
+	status := span{}
+	switch {
+	case a.mode == filtering:
+		status.text = "/" + a.filter.String()
+		cursorRow, cursorCol = a.height, 2+a.filter.pos
+	case a.mode == editing && a.field == "text":
+		status.text = "Enter save  Esc cancel  " + a.message
+	case a.mode == editing:
+		label := a.field + ": "
+		if a.field == "new" {
+			label = "new task: "
+		} else if n := len(a.targets()); n > 1 {
+			label = fmt.Sprintf("%s of %d tasks: ", a.field, n)
+		}
+		status.text = label + a.input.String()
+		if a.message != "" {
+			status.text += "  " + a.message
+		}
+		cursorRow, cursorCol = a.height, utf8.RuneCountInString(label)+a.input.pos+1
+	case a.mode == confirming:
+		status.text = fmt.Sprintf("Delete %d task(s)? y/n", len(a.targets()))
+	case a.message != "":
+		status.text = a.message
+	default:
+		status = span{hints, "gray"}
+	}
+	line(a.height, render([]span{status}, a.width-1, false))
+	if cursorRow > 0 {
+		fmt.Fprintf(&b, "\x1b[%d;%dH\x1b[?25h", cursorRow, min(cursorCol, a.width-1))
+	}
+	os.Stdout.WriteString(b.String())
+}
+
+func (a *app) selectedShown() []api.Task {
+	var selected []api.Task
+	for _, t := range a.shown {
+		if a.selected[t.ID] {
+			selected = append(selected, t)
+		}
+	}
+	return selected
+}
+
+// row is a task in the list. When editing, the text is the one typed.
+func (a *app) row(t api.Task, editing bool) []span {
+	cfg := config.Get()
+	mark := "  "
+	if a.selected[t.ID] {
+		mark = "* "
+	}
+	status, _ := cfg.Status(t.Status)
+	priority, _ := cfg.Priority(t.Priority)
+	due := ""
+	if t.Due != nil {
+		due = *t.Due
+	}
+	spans := []span{
+		{mark, "cyan"},
+		{fit(fmt.Sprint(t.ID), 4) + " ", ""},
+		{fit(t.Status, 10) + " ", status.Color},
+		{fit(t.Priority, 8) + " ", priority.Color},
+		{fit(due, 16) + " ", ""},
+	}
+	if editing {
+		return append(spans, span{a.input.String(), ""})
+	}
+	spans = append(spans, span{t.Text, ""})
+	for _, tag := range t.Tags {
+		spans = append(spans, span{" #" + tag, "gray"})
+	}
+	if t.Recur != nil {
+		spans = append(spans, span{" ↻", "gray"})
+	}
+	return spans
+}
+
+// details are the lines of the detail pane on the task under the cursor.
+func (a *app) details(width int) []string {
+	t, ok := a.current()
+	if !ok {
+		return []string{"No task"}
+	}
+	if a.notesOf != t.ID {
+		notes, err := api.Notes(a.db, t.ID)
+		if err != nil {
+			a.message = err.Error()
+		}
+		a.notes, a.notesOf = notes, t.ID
+	}
+	lines := wrap(fmt.Sprintf("#%d %s", t.ID, t.Text), width)
+	field := func(name, value string) {
+		if value != "" {
+			lines = append(lines, fit(fmt.Sprintf("%-10s %s", name, value), width))
+		}
+	}
+	lines = append(lines, "")
+	field("status", t.Status)
+	field("priority", t.Priority)
+	if t.Due != nil {
+		field("due", *t.Due)
+	}
+	field("tags", strings.Join(t.Tags, ", "))
+	if t.Project != nil {
+		field("project", *t.Project)
+	}
+	if t.Estimate > 0 || t.Logged > 0 {
+		field("estimate", fmt.Sprintf("%s, %s logged", duration(t.Estimate), duration(t.Logged)))
+	}
+	if t.Recur != nil {
+		field("recur", *t.Recur)
+	}
+	field("created", t.CreatedAt.Local().Format("2006-01-02 15:04"))
+	field("updated", t.UpdatedAt.Local().Format("2006-01-02 15:04"))
+	if len(a.notes) > 0 {
+		lines = append(lines, "", "Notes")
+		for _, n := range a.notes {
+			lines = append(lines, wrap(n.CreatedAt.Local().Format("2006-01-02 15:04")+"  "+n.Text, width)...)
+		}
+	}
+	return lines
+}
+
+func duration(seconds int) string {
+	return service.FormatDuration(time.Duration(seconds) * time.Second)
+}
+
+// render joins spans into a row of exactly width columns, reversed for
+// the cursor row.
+func render(spans []span, width int, reverse bool) string {
+	var b strings.Builder
+	if reverse {
+		b.WriteString("\x1b[7m")
+	}
+	left := width
+	for _, s := range spans {
+		if left <= 0 {
+			break
+		}
+		text := s.text
+		if n := utf8.RuneCountInString(text); n > left {
+			text = string([]rune(text)[:left])
+		}
+		left -= utf8.RuneCountInString(text)
+		if code, exists := colors[s.color]; exists && !reverse {
+			text = "\x1b[" + code + "m" + text + "\x1b[0m"
+		}
+		b.WriteString(text)
+	}
+	b.WriteString(strings.Repeat(" ", left))
+	if reverse {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
+
+// fit pads or cuts s to n runes.
+func fit(s string, n int) string {
+	if r := []rune(s); len(r) > n {
+		return string(r[:max(n, 0)])
+	}
+	return s + strings.Repeat(" ", n-utf8.RuneCountInString(s))
+}
+
+// wrap breaks text into lines of at most width runes at spaces.
+func wrap(text string, width int) []string {
+	var lines []string
+	current := ""
+	for _, word := range strings.Fields(text) {
+		switch {
+		case current == "":
+			current = word
+		case utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) <= width:
+			current += " " + word
+		default:
+			lines = append(lines, current)
+			current = word
+		}
+		for utf8.RuneCountInString(current) > width {
+			r := []rune(current)
+			lines = append(lines, string(r[:width]))
+			current = string(r[width:])
+		}
+	}
+	return append(lines, current)
+}
+
+func at(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return ""
+}
@@ -0,0 +1,118 @@
+package tui
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+// key is a key press, a named key or a typed rune.
+type key struct {
+	name string // up, down, enter, esc, ctrl-c, ... or "" for r
+	r    rune
+}
+
+// escapes are the sequences terminals send for the named keys, after
+// ESC [ or ESC O.
+var escapes = map[string]string{
+	"A": "up", "B": "down", "C": "right", "D": "left",
+	"H": "home", "F": "end", "1~": "home", "4~": "end", "7~": "home", "8~": "end",
+	"3~": "delete", "5~": "pgup", "6~": "pgdn", "Z": "backtab",
+}
+
+var controls = map[byte]string{
+	'\r': "enter", '\n': "enter", '\t': "tab", 0x7f: "backspace", 0x08: "backspace",
+	0x03: "ctrl-c", 0x15: "ctrl-u", 0x01: "home", 0x05: "end",
+}
+
+// readKeys sends the keys read from r until it fails.
+func readKeys(r io.Reader, keys chan<- []key) {
+	buf := make([]byte, 256)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		keys <- parseKeys(buf[:n])
+	}
+}
+
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 0x1b && len(b) > 2 && (b[1] == '[' || b[1] == 'O'):
+			// the sequence ends with a byte in @ to ~
+			end := 2
+			for end < len(b) && (b[end] < 0x40 || b[end] > 0x7e) {
+				end++
+			}
+			if end == len(b) {
+				end--
+			}
+			if name, exists := escapes[string(b[2:end+1])]; exists {
+				keys = append(keys, key{name: name})
+			}
+			b = b[end+1:]
+		case c == 0x1b:
+			keys = append(keys, key{name: "esc"})
+			b = b[1:]
+		case c < 0x20 || c == 0x7f:
+			if name, exists := controls[c]; exists {
+				keys = append(keys, key{name: name})
+			}
+			b = b[1:]
+		default:
+			r, n := utf8.DecodeRune(b)
+			keys = append(keys, key{r: r})
+			b = b[n:]
+		}
+	}
+	return keys
+}
+
+// line is a one line text editor.
+type line struct {
+	buf []rune
+	pos int
+}
+
+func (l *line) set(s string) {
+	l.buf = []rune(s)
+	l.pos = len(l.buf)
+}
+
+func (l *line) String() string {
+	return string(l.buf)
+}
+
+// edit applies an editing key, reporting whether it was one.
+func (l *line) edit(k key) bool {
+	switch k.name {
+	case "":
+		l.buf = append(l.buf[:l.pos], append([]rune{k.r}, l.buf[l.pos:]...)...)
+		l.pos++
+	case "backspace":
+		if l.pos > 0 {
+			l.buf = append(l.buf[:l.pos-1], l.buf[l.pos:]...)
+			l.pos--
+		}
+	case "delete":
+		if l.pos < len(l.buf) {
+			l.buf = append(l.buf[:l.pos], l.buf[l.pos+1:]...)
+		}
+	case "left":
+		l.pos = max(l.pos-1, 0)
+	case "right":
+		l.pos = min(l.pos+1, len(l.buf))
+	case "home":
+		l.pos = 0
+	case "end":
+		l.pos = len(l.buf)
+	case "ctrl-u":
+		l.buf, l.pos = l.buf[:0], 0
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,9 @@
+//go:build !unix
+
+package tui
+
+import "os"
+
+// notifyResize does nothing, there is no resize signal; the size is read
+// again after every key.
+func notifyResize(c chan<- os.Signal) {}
@@ -0,0 +1,6 @@
+package tui
+
+import "syscall"
+
+// the ioctl requests that get and set the terminal mode
+const getTermios, setTermios = syscall.TCGETS, syscall.TCSETS
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+
+package tui
+
+import "errors"
+
+// makeRaw is only implemented for Linux and macOS terminals.
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("the tui needs Linux or macOS")
+}
+
+func size(fd int) (int, int, error) {
+	return 0, 0, errors.New("the tui needs Linux or macOS")
+}
@@ -0,0 +1,443 @@
+// Package tui is a full-screen, keyboard driven interface on the todos:
+// a scrollable list with a live filter, a detail pane with the notes,
+// and keys that change the task under the cursor or every selected one.
+// Writes go through the same store as the CLI, recorded in the history
+// with source tui.
+package tui
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+
+	"github.com/benpsk/todo/cmd/api"
+	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+type mode int
+
+const (
+	browsing   mode = iota
+	filtering       // typing the filter
+	editing         // typing a field of the targets, or a new task
+	confirming      // asked whether to delete
+)
+
+// fields are the keys that edit a field and the field they edit.
+var fields = map[rune]string{'e': "text", 'd': "due", 't': "tags", 'p': "project"}
+
+type app struct {
+	db       *sql.DB
+	tasks    []api.Task // every task that is not archived
+	shown    []api.Task // the ones matching the filter
+	selected map[int]bool
+	cursor   int // index in shown
+	top      int // first row of shown on screen
+	mode     mode
+	filter   line
+	field    string // being edited, "new" for a new task
+	input    line
+	detail   bool
+	notes    []api.Note
+	notesOf  int // id the notes are of
+	message  string
+	width    int
+	height   int
+}
+
+// Run runs the interface on the terminal until q is pressed.
+func Run(conn *sql.DB) error {
+	fd := int(os.Stdin.Fd())
+	restore, err := makeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("not a terminal: %w", err)
+	}
+	defer restore()
+
+	a := &app{db: conn, selected: map[int]bool{}, notesOf: -1}
+	if err := a.load(); err != nil {
+		return err
+	}
+	// alternate screen, cursor hidden
+	os.Stdout.WriteString("\x1b[?1049h\x1b[?25l")
+	defer os.Stdout.WriteString("\x1b[?25h\x1b[?1049l")
+
+	resized := make(chan os.Signal, 1)
+	notifyResize(resized)
+	defer signal.Stop(resized)
+	keys := make(chan []key)
+	go readKeys(os.Stdin, keys)
+
+	for {
+		a.width, a.height, err = size(fd)
+		if err != nil || a.width < 20 || a.height < 5 {
+			a.width, a.height = max(a.width, 80), max(a.height, 24)
+		}
+		a.draw()
+		select {
+		case <-resized:
+		case ks, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, k := range ks {
+				if quit := a.handle(k); quit {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// load reads the tasks again, keeping the cursor on the same task.
+func (a *app) load() error {
+	tasks, err := api.List(a.db, &service.Filter{})
+	if err != nil {
+		return err
+	}
+	a.tasks = tasks
+	a.notesOf = -1
+	a.applyFilter()
+	return nil
+}
+
+func (a *app) reload() {
+	if err := a.load(); err != nil {
+		a.message = err.Error()
+	}
+}
+
+// applyFilter keeps the tasks that have every word of the filter in
+// their text, status, priority, tags, project or #id.
+func (a *app) applyFilter() {
+	id := -1
+	if t, ok := a.current(); ok {
+		id = t.ID
+	}
+	words := strings.Fields(strings.ToLower(a.filter.String()))
+	a.shown = a.shown[:0]
+	for _, t := range a.tasks {
+		haystack := strings.ToLower(fmt.Sprintf("%s %s %s #%d %s", t.Text, t.Status, t.Priority, t.ID, strings.Join(t.Tags, " ")))
+		if t.Project != nil {
+			haystack += " " + strings.ToLower(*t.Project)
+		}
+		if slices.IndexFunc(words, func(w string) bool { return !strings.Contains(haystack, w) }) < 0 {
+			a.shown = append(a.shown, t)
+		}
+	}
+	a.cursor = min(a.cursor, max(len(a.shown)-1, 0))
+	a.moveTo(id)
+}
+
+func (a *app) current() (api.Task, bool) {
+	if a.cursor < len(a.shown) {
+		return a.shown[a.cursor], true
+	}
+	return api.Task{}, false
+}
+
+func (a *app) moveTo(id int) {
+	if i := slices.IndexFunc(a.shown, func(t api.Task) bool { return t.ID == id }); i >= 0 {
+		a.cursor = i
+	}
+}
+
+// targets are the selected tasks that are shown, or else the one under
+// the cursor.
+func (a *app) targets() []api.Task {
+	var targets []api.Task
+	for _, t := range a.shown {
+		if a.selected[t.ID] {
+			targets = append(targets, t)
+		}
+	}
+	if len(targets) == 0 {
+		if t, ok := a.current(); ok {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// handle applies a key, reporting whether to quit.
+func (a *app) handle(k key) bool {
+	a.message = ""
+	switch a.mode {
+	case filtering:
+		switch k.name {
+		case "enter":
+			a.mode = browsing
+		case "esc":
+			a.filter.set("")
+			a.mode = browsing
+		default:
+			a.filter.edit(k)
+		}
+		a.applyFilter()
+		return false
+	case editing:
+		switch k.name {
+		case "enter":
+			// a value that is not valid stays to be fixed
+			if a.save() {
+				a.mode = browsing
+			}
+		case "esc":
+			a.mode = browsing
+		default:
+			a.input.edit(k)
+		}
+		return false
+	case confirming:
+		if k.r == 'y' || k.r == 'Y' {
+			a.delete()
+		}
+		a.mode = browsing
+		return false
+	}
+
+	switch {
+	case k.name == "ctrl-c" || k.r == 'q':
+		return true
+	case k.name == "down" || k.r == 'j':
+		a.cursor = min(a.cursor+1, max(len(a.shown)-1, 0))
+	case k.name == "up" || k.r == 'k':
+		a.cursor = max(a.cursor-1, 0)
+	case k.name == "pgdn":
+		a.cursor = min(a.cursor+a.rows(), max(len(a.shown)-1, 0))
+	case k.name == "pgup":
+		a.cursor = max(a.cursor-a.rows(), 0)
+	case k.name == "home" || k.r == 'g':
+		a.cursor = 0
+	case k.name == "end" || k.r == 'G':
+		a.cursor = max(len(a.shown)-1, 0)
+	case k.r == '/':
+		a.mode = filtering
+	case k.name == "esc":
+		a.filter.set("")
+		a.applyFilter()
+	case k.r == ' ':
+		if t, ok := a.current(); ok {
+			a.selected[t.ID] = !a.selected[t.ID]
+			a.cursor = min(a.cursor+1, len(a.shown)-1)
+		}
+	case k.r == 'V':
+		// select every task shown, or none when they all are
+		all := len(a.shown) > 0 && !slices.ContainsFunc(a.shown, func(t api.Task) bool { return !a.selected[t.ID] })
+		for _, t := range a.shown {
+			a.selected[t.ID] = !all
+		}
+	case k.name == "enter" || k.name == "tab":
+		a.detail = !a.detail
+	case k.r == 's':
+		a.cycleStatus()
+	case k.r == 'x':
+		if done, ok := doneStatus(); ok {
+			a.setStatus(done)
+		}
+	case k.r >= '1' && k.r <= '9':
+		if i := int(k.r - '1'); i < len(config.Get().Statuses) {
+			a.setStatus(config.Get().Statuses[i])
+		}
+	case k.r == '+' || k.r == '=':
+		a.shiftPriority(1)
+	case k.r == '-':
+		a.shiftPriority(-1)
+	case fields[k.r] != "":
+		if t, ok := a.current(); ok {
+			a.startEdit(fields[k.r], t)
+		}
+	case k.r == 'a':
+		a.field = "new"
+		a.input.set("")
+		a.mode = editing
+	case k.r == 'X' || k.name == "delete":
+		if len(a.targets()) > 0 {
+			a.mode = confirming
+		}
+	case k.r == 'R':
+		a.reload()
+	}
+	return false
+}
+
+func (a *app) startEdit(field string, t api.Task) {
+	value := ""
+	switch field {
+	case "text":
+		value = t.Text
+	case "due":
+		if t.Due != nil {
+			value = *t.Due
+		}
+	case "tags":
+		value = strings.Join(t.Tags, ",")
+	case "project":
+		if t.Project != nil {
+			value = *t.Project
+		}
+	}
+	a.field = field
+	a.input.set(value)
+	a.mode = editing
+}
+
+// save writes the field being edited to the targets, text to the task
+// under the cursor only. It reports whether the value was valid.
+func (a *app) save() bool {
+	value := a.input.String()
+	var in api.Input
+	switch a.field {
+	case "new", "text":
+		in.Text = &value
+	case "due":
+		in.Due = &value
+	case "tags":
+		tags := strings.Split(value, ",")
+		in.Tags = &tags
+	case "project":
+		in.Project = &value
+	}
+	if in.Text != nil && strings.TrimSpace(value) == "" {
+		a.message = "The text cannot be empty"
+		return false
+	}
+	fields, problems := in.Fields(a.field == "new")
+	if len(problems) > 0 {
+		a.message = strings.Join(problems, "; ")
+		return false
+	}
+	if a.field == "new" {
+		id, err := db.Insert(a.db, fields, db.SourceTUI)
+		if err != nil {
+			a.message = err.Error()
+			return true
+		}
+		a.reload()
+		a.moveTo(id)
+		a.message = fmt.Sprintf("Added #%d", id)
+		return true
+	}
+	targets := a.targets()
+	if a.field == "text" {
+		t, _ := a.current()
+		targets = []api.Task{t}
+	}
+	if a.update(ids(targets), fields) {
+		a.message = fmt.Sprintf("%s of %d task(s) changed", a.field, len(targets))
+	}
+	return true
+}
+
+// update writes fields to ids, reporting whether it did.
+func (a *app) update(ids []int, fields map[string]any) bool {
+	if len(ids) == 0 {
+		return false
+	}
+	if err := db.Update(a.db, ids, fields, db.SourceTUI); err != nil {
+		a.message = err.Error()
+		return false
+	}
+	a.reload()
+	return true
+}
+
+// setStatus moves the targets the workflow allows to status.
+func (a *app) setStatus(status config.Status) {
+	var allowed []int
+	skipped := 0
+	for _, t := range a.targets() {
+		if from, exists := config.Get().Status(t.Status); exists && !from.Allows(status) {
+			skipped++
+			continue
+		}
+		allowed = append(allowed, t.ID)
+	}
+	msg := fmt.Sprintf("%d task(s) set to %s", len(allowed), status.Name)
+	if skipped > 0 {
+		msg += fmt.Sprintf(", %d cannot move to %s", skipped, status.Name)
+	}
+	a.message = msg
+	a.update(allowed, map[string]any{"status": status.Value})
+}
+
+// cycleStatus moves the targets to the status after the one of the task
+// under the cursor that it may move to.
+func (a *app) cycleStatus() {
+	t, ok := a.current()
+	if !ok {
+		return
+	}
+	statuses := config.Get().Statuses
+	from, exists := config.Get().Status(t.Status)
+	i := slices.IndexFunc(statuses, func(s config.Status) bool { return s.Value == from.Value })
+	if !exists || i < 0 {
+		return
+	}
+	for n := 1; n < len(statuses); n++ {
+		if next := statuses[(i+n)%len(statuses)]; from.Allows(next) {
+			a.setStatus(next)
+			return
+		}
+	}
+}
+
+// shiftPriority raises or lowers the priority of the targets one step.
+func (a *app) shiftPriority(by int) {
+	priorities := config.Get().Priorities
+	byWeight := map[int][]int{}
+	for _, t := range a.targets() {
+		i := slices.IndexFunc(priorities, func(p config.Priority) bool { return p.Name == t.Priority })
+		if j := i + by; i >= 0 && j >= 0 && j < len(priorities) {
+			byWeight[priorities[j].Weight] = append(byWeight[priorities[j].Weight], t.ID)
+		}
+	}
+	changed := 0
+	for weight, ids := range byWeight {
+		if !a.update(ids, map[string]any{"priority": weight}) {
+			return
+		}
+		changed += len(ids)
+	}
+	a.message = fmt.Sprintf("Priority of %d task(s) changed", changed)
+}
+
+func (a *app) delete() {
+	targets := a.targets()
+	if err := db.Delete(a.db, ids(targets), db.SourceTUI); err != nil {
+		a.message = err.Error()
+		return
+	}
+	for _, t := range targets {
+		delete(a.selected, t.ID)
+	}
+	a.reload()
+	a.message = fmt.Sprintf("Deleted %d task(s), todo undo %d brings them back", len(targets), len(targets))
+}
+
+// doneStatus is done, or the first terminal status.
+func doneStatus() (config.Status, bool) {
+	cfg := config.Get()
+	if done, exists := cfg.Status("done"); exists {
+		return done, true
+	}
+	for _, s := range cfg.Statuses {
+		if s.Terminal {
+			return s, true
+		}
+	}
+	return config.Status{}, false
+}
+
+func ids(tasks []api.Task) []int {
+	ids := make([]int, len(tasks))
+	for i, t := range tasks {
+		ids[i] = t.ID
+	}
+	return ids
+}
@@ -0,0 +1,32 @@
+package ui
+
+import "os"
+
+var colors = map[string]string{
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+	"gray":    "90",
+}
+
+// Color wraps text in the named ANSI color when stdout is a terminal.
+// Unknown colors leave the text unchanged.
+func Color(name, text string) string {
+	code, exists := colors[name]
+	if !exists || !isTerminal() {
+		return text
+	}
+	return "\033[" + code + "m" + text + "\033[0m"
+}
+
+func isTerminal() bool {
+	fi, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
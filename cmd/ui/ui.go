@@ -11,8 +11,26 @@ Usage:
 Commands:
   add       Add a new task
   list      List tasks
+  show      Show a task with its reminders
   update    Update existing tasks
   delete    Delete tasks
+  archive   Archive tasks (finished tasks by default)
+  remind    Add or list reminders of a task
+  snooze    Postpone the reminders of a task
+  start     Start a timer on a task
+  stop      Stop the running timer
+  log       Log time spent on a task
+  report    Report logged time
+  daemon    Run the reminder daemon (status|start|stop|jobs|reload|run|pause|logs|install)
+  dnd       Hold notifications back (on [--for=2h]|off|status)
+  import    Import tasks from another tool (--format=todotxt|taskwarrior|ics|markdown)
+  export    Export tasks for another tool (--format=todotxt|taskwarrior|ics|markdown)
+  backup    Write every table to a JSON backup
+  restore   Restore a backup (--merge|--replace)
+  serve     Serve the tasks over HTTP as JSON and a web UI at /ui/ (--addr=127.0.0.1:8080)
+  tui       Triage tasks in a full-screen terminal view
+  history   Show how a task changed over time
+  undo      Revert the last n operations (default 1)
 
 Examples:
 
@@ -23,6 +41,7 @@ Examples:
   List tasks: [filter by last 7 due days]
     todo list --status=done --priority=high --due=wed-20:19 --created=wed --find=task1
     todo ls -s done -p high -d wed-20:19 -c wed -f task1
+    todo ls "--priority>=high"
 
   Update tasks:
     todo update 1 2 3 --status=done --priority=high --due=wed-20:19
@@ -31,13 +50,74 @@ Examples:
   Delete tasks:
     todo delete 1 2 3
 
+  Archive tasks:
+    todo archive
+    todo archive 1 2 3
+    todo archive --tag=project1 --status=cancelled
+    todo list --archived --find=task1
+
+  Reminders:
+    todo remind 5 --at="tomorrow 09:00"
+    todo remind 5 --before=30m
+    todo remind 5
+    todo remind 5 --clear
+    todo snooze 5 1h
+
+  Time tracking:
+    todo start 3
+    todo stop
+    todo log 3 1h30m --date=yesterday
+    todo report time --by=tag --range=this-week
+    todo report time --by=project --range=last-month
+    todo report estimate --by=person
+
+  Estimates:
+    todo add "new task" --estimate=2h
+    todo list --fits=45m
+
+  Daemon:
+    todo daemon start --detach
+    todo daemon status
+    todo daemon logs -f
+    todo daemon run digest:morning
+    todo daemon pause 2h
+    todo dnd on --for=2h
+
+  Import and export:
+    todo import --format=todotxt ~/todo.txt
+    todo export --format=todotxt --project=work -o work.txt
+    task export | todo import --format=taskwarrior -
+    todo export --format=taskwarrior -o tasks.json
+    todo export --format=ics --as-events -o todo.ics
+    todo import --format=markdown --headings=tag notes.md
+    todo export --format=markdown --group-by=tag
+
+  Backup and restore:
+    todo backup
+    todo backup - > todos.json
+    todo restore ~/.todo-backups/todos-2025-01-02.json
+    todo restore --replace todos.json
+
+  HTTP API:
+    todo serve --addr=127.0.0.1:8080
+    curl localhost:8080/tasks?status=pending
+    open http://localhost:8080/ui/board
+
+  History and undo:
+    todo history 3
+    todo undo 2
+
 Options:
-  -p, --priority   Set task priority (low|medium|high)
-  -s, --status     Set task status (pending|processing|done)
+  -p, --priority   Set task priority (none|low|medium|high|urgent, aliases !|!!|!!!, l|m|h, p0-p3)
+  -s, --status     Set task status (pending|processing|done|blocked|review|waiting|cancelled)
   -d, --due        Set due date (e.g. 2025, 2025-01, fri, 2025-01-01)
   -t, --tag        Add one or more tags (eg. "p1,ui")
+  -P, --project    Set the project (eg. website)
+  -e, --estimate   Set the estimated effort (eg. 2h, 45m)
+      --fits       Filter tasks whose remaining estimate fits (eg. 45m)
   -c, --created    Filter by creation date (eg. 2025, 2025-01, fri, 2025-01-01) 
   -f, --find       Search for keyword in task 
+      --archived   List archived tasks only
 
 Enjoy!`)
 }
@@ -9,6 +9,8 @@ import (
 	"strings"
 
 	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
 )
 
 type updateFlag struct {
@@ -18,6 +20,8 @@ type updateFlag struct {
 	priority string
 	due      *string
 	tag      *string
+	project  string
+	estimate int // seconds
 }
 
 func (a *updateFlag) GetStatus() string    { return a.status }
@@ -54,6 +58,11 @@ func parseUpdate() *updateFlag {
 		os.Exit(1)
 	}
 
+	estimate, err := service.ParseEstimate(*parse.Estimate)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
   var due string
   if parse.Due != nil {
     due = strings.ToLower(*parse.Due)
@@ -65,44 +74,67 @@ func parseUpdate() *updateFlag {
 		priority: strings.ToLower(*parse.Priority),
 		due:      &due,
 		tag:      parse.Tag,
+		project:  *parse.Project,
+		estimate: estimate,
 	}
 }
 
 func (app *App) updateTodo(cmd *updateFlag) error {
-	query := `
-    update todos 
-    set updated_at = CURRENT_TIMESTAMP
-  `
-	var args []interface{}
+	fields := map[string]any{}
 	if cmd.text != "" {
-		query += ", text=?"
-		args = append(args, cmd.text)
+		fields["text"] = cmd.text
 	}
 	if cmd.status != "" {
-		query += ", status=?"
-		args = append(args, cmd.status)
+		fields["status"] = cmd.status
 	}
 	if cmd.priority != "" {
-		query += ", priority=?"
-		args = append(args, cmd.priority)
+		fields["priority"] = cmd.priority
 	}
 	if cmd.due != nil {
-		query += ", due=?"
-		args = append(args, cmd.due)
+		fields["due"] = cmd.due
 	}
 	if *cmd.tag != "" {
-		query += ", tag=?"
-		args = append(args, cmd.tag)
+		fields["tag"] = cmd.tag
+	}
+	if cmd.project != "" {
+		fields["project"] = cmd.project
+	}
+	if cmd.estimate > 0 {
+		fields["estimate"] = cmd.estimate
 	}
-	placeholders := make([]string, len(cmd.ids))
+	return db.Update(app.db, cmd.ids, fields, db.SourceCLI)
+}
+
+// isValidTransition rejects status changes the workflow does not allow.
+func (app *App) isValidTransition(cmd *updateFlag) (bool, error) {
+	if cmd.status == "" {
+		return true, nil
+	}
+	next, _ := config.Get().Status(cmd.status)
+	args := make([]interface{}, len(cmd.ids))
 	for i, id := range cmd.ids {
-		placeholders[i] = "?"
-		args = append(args, id)
+		args[i] = id
+	}
+	rows, err := app.db.Query("select id, status from todos where id in ("+db.Placeholders(len(cmd.ids))+")", args...)
+	if err != nil {
+		return false, err
 	}
-	query += " where id in (" + strings.Join(placeholders, ",") + ")"
+	defer rows.Close()
 
-	_, err := app.db.Exec(query, args...)
-	return err
+	isValid := true
+	for rows.Next() {
+		var id int
+		var status string
+		if err := rows.Scan(&id, &status); err != nil {
+			return false, err
+		}
+		current, exists := config.Get().Status(status)
+		if exists && !current.Allows(next) {
+			fmt.Fprintf(os.Stderr, "id %d: cannot move from %v to %v\n", id, current.Name, next.Name)
+			isValid = false
+		}
+	}
+	return isValid, rows.Err()
 }
 
 func (app *App) update() {
@@ -110,6 +142,13 @@ func (app *App) update() {
 	if isValid := service.Validate(cmd); !isValid {
 		os.Exit(1)
 	}
+	isValid, err := app.isValidTransition(cmd)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !isValid {
+		os.Exit(1)
+	}
 	if err := app.updateTodo(cmd); err != nil {
 		log.Fatal(err)
 	}
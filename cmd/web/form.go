@@ -0,0 +1,85 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benpsk/todo/cmd/api"
+	"github.com/benpsk/todo/cmd/service"
+)
+
+// form is the add and edit form, as typed.
+type form struct {
+	ID       int
+	Version  int64
+	From     string // status the task is in, empty for a new one
+	Text     string
+	Status   string
+	Priority string
+	Due      string
+	Tags     string
+	Project  string
+	Estimate string
+}
+
+func formFor(t api.Task) form {
+	f := form{
+		ID:       t.ID,
+		Version:  t.Version(),
+		From:     t.Status,
+		Text:     t.Text,
+		Status:   t.Status,
+		Priority: t.Priority,
+		Tags:     strings.Join(t.Tags, ","),
+	}
+	if t.Due != nil {
+		f.Due = *t.Due
+	}
+	if t.Project != nil {
+		f.Project = *t.Project
+	}
+	if t.Estimate > 0 {
+		f.Estimate = service.FormatDuration(time.Duration(t.Estimate) * time.Second)
+	}
+	return f
+}
+
+func formOf(r *http.Request) form {
+	f := form{
+		Text:     r.FormValue("text"),
+		Status:   r.FormValue("status"),
+		Priority: r.FormValue("priority"),
+		Due:      r.FormValue("due"),
+		Tags:     r.FormValue("tags"),
+		Project:  r.FormValue("project"),
+		Estimate: r.FormValue("estimate"),
+	}
+	f.Version, _ = strconv.ParseInt(r.FormValue("version"), 10, 64)
+	return f
+}
+
+// input sets every field of the form, an empty one clears it.
+func (f form) input() (api.Input, []string) {
+	tags := strings.Split(f.Tags, ",")
+	in := api.Input{
+		Text:    &f.Text,
+		Due:     &f.Due,
+		Tags:    &tags,
+		Project: &f.Project,
+	}
+	if f.Status != "" {
+		in.Status = &f.Status
+	}
+	if f.Priority != "" {
+		in.Priority = &f.Priority
+	}
+	var problems []string
+	estimate, err := service.ParseEstimate(strings.TrimSpace(f.Estimate))
+	if err != nil {
+		problems = append(problems, err.Error())
+	}
+	in.Estimate = &estimate
+	return in, problems
+}
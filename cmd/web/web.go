@@ -0,0 +1,361 @@
+// Package web is a small browser interface on the todos, rendered on the
+// server from the templates embedded in the binary. It is served under
+// /ui/ next to the API and writes through the same store, recorded in the
+// history with source web.
+package web
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/benpsk/todo/cmd/api"
+	"github.com/benpsk/todo/cmd/service"
+	"github.com/benpsk/todo/config"
+	"github.com/benpsk/todo/db"
+)
+
+//go:embed templates/*.html static/*
+var files embed.FS
+
+// cookie keeps the token of a browser that opened /ui/?token=TOKEN.
+const cookie = "todo_token"
+
+var funcs = template.FuncMap{
+	"color":    color,
+	"duration": func(seconds int) string { return service.FormatDuration(time.Duration(seconds) * time.Second) },
+	"join":     func(tags []string) string { return strings.Join(tags, ", ") },
+	"terminal": func(status string) bool { s, _ := config.Get().Status(status); return s.Terminal },
+	"next":     next,
+}
+
+// pages are parsed once, each with the layout.
+var pages = map[string]*template.Template{}
+
+func init() {
+	for _, page := range []string{"list", "board", "form"} {
+		pages[page] = template.Must(template.New("layout.html").Funcs(funcs).
+			ParseFS(files, "templates/layout.html", "templates/"+page+".html"))
+	}
+}
+
+type server struct {
+	db    *sql.DB
+	token string
+}
+
+// New returns the handler of /ui/. A token, when not empty, is asked for
+// once as /ui/?token=TOKEN and then kept in a cookie.
+func New(conn *sql.DB, token string) http.Handler {
+	s := &server{db: conn, token: token}
+	mux := http.NewServeMux()
+	mux.Handle("GET /ui/static/", http.StripPrefix("/ui/", http.FileServerFS(files)))
+	mux.HandleFunc("GET /ui/{$}", s.auth(s.list))
+	mux.HandleFunc("GET /ui/board", s.auth(s.board))
+	mux.HandleFunc("GET /ui/new", s.auth(s.new))
+	mux.HandleFunc("POST /ui/tasks", s.auth(s.create))
+	mux.HandleFunc("GET /ui/tasks/{id}", s.auth(s.edit))
+	mux.HandleFunc("POST /ui/tasks/{id}", s.auth(s.save))
+	mux.HandleFunc("POST /ui/tasks/{id}/complete", s.auth(s.complete))
+	mux.HandleFunc("POST /ui/tasks/{id}/status", s.auth(s.move))
+	return mux
+}
+
+func (s *server) auth(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// a form posted from another site is refused, the browser would
+		// send the cookie along
+		if origin := r.Header.Get("Origin"); r.Method == http.MethodPost && origin != "" {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				http.Error(w, "cross-origin request refused", http.StatusForbidden)
+				return
+			}
+		}
+		if s.token != "" {
+			if given := r.URL.Query().Get("token"); given != "" && equal(given, s.token) {
+				http.SetCookie(w, &http.Cookie{Name: cookie, Value: given, Path: "/ui/",
+					HttpOnly: true, SameSite: http.SameSiteStrictMode})
+				http.Redirect(w, r, r.URL.Path, http.StatusSeeOther)
+				return
+			}
+			c, err := r.Cookie(cookie)
+			if err != nil || !equal(c.Value, s.token) {
+				http.Error(w, "open /ui/?token=TOKEN with the api token of ~/.todo.json", http.StatusUnauthorized)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+func equal(given, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// page is what every template gets.
+type page struct {
+	Title      string
+	Error      string
+	Query      url.Values
+	Back       string // where a form posted from the page returns to
+	Statuses   []config.Status
+	Priorities []config.Priority
+	Tasks      []api.Task
+	Columns    []column
+	Form       form
+	Notes      []api.Note
+}
+
+type column struct {
+	Status config.Status
+	Tasks  []api.Task
+}
+
+func (s *server) render(w http.ResponseWriter, r *http.Request, name string, code int, p page) {
+	cfg := config.Get()
+	p.Statuses, p.Priorities = cfg.Statuses, cfg.Priorities
+	p.Query = r.URL.Query()
+	if p.Error == "" {
+		p.Error = p.Query.Get("error")
+	}
+	p.Back = r.URL.Path
+	if q := r.URL.Query(); len(q) > 0 {
+		q.Del("error")
+		p.Back += "?" + q.Encode()
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	if err := pages[name].Execute(w, p); err != nil {
+		fmt.Fprintf(w, "<p>%s</p>", template.HTMLEscapeString(err.Error()))
+	}
+}
+
+// filtered lists the tasks matching the query, the problems go to p.
+func (s *server) filtered(r *http.Request, p *page) []api.Task {
+	f, problems := api.FilterOf(r.URL.Query())
+	if len(problems) > 0 {
+		p.Error = strings.Join(problems, "; ")
+		f = &service.Filter{}
+	}
+	tasks, err := api.List(s.db, f)
+	if err != nil {
+		p.Error = err.Error()
+	}
+	return tasks
+}
+
+func (s *server) list(w http.ResponseWriter, r *http.Request) {
+	p := page{Title: "Tasks"}
+	p.Tasks = s.filtered(r, &p)
+	s.render(w, r, "list", http.StatusOK, p)
+}
+
+func (s *server) board(w http.ResponseWriter, r *http.Request) {
+	p := page{Title: "Board"}
+	tasks := s.filtered(r, &p)
+	for _, status := range config.Get().Statuses {
+		c := column{Status: status}
+		for _, t := range tasks {
+			if t.Status == status.Name {
+				c.Tasks = append(c.Tasks, t)
+			}
+		}
+		p.Columns = append(p.Columns, c)
+	}
+	s.render(w, r, "board", http.StatusOK, p)
+}
+
+func (s *server) new(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Get()
+	f := form{Status: cfg.NewStatus().Name, Priority: cfg.NewPriority().Name}
+	s.render(w, r, "form", http.StatusOK, page{Title: "New task", Form: f})
+}
+
+func (s *server) create(w http.ResponseWriter, r *http.Request) {
+	f := formOf(r)
+	in, problems := f.input()
+	if strings.TrimSpace(f.Text) == "" {
+		problems = append(problems, "text is required")
+	}
+	fields, more := in.Fields(true)
+	if problems = append(problems, more...); len(problems) > 0 {
+		s.render(w, r, "form", http.StatusBadRequest, page{Title: "New task", Form: f, Error: strings.Join(problems, "; ")})
+		return
+	}
+	if _, err := db.Insert(s.db, fields, db.SourceWeb); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/", http.StatusSeeOther)
+}
+
+func (s *server) edit(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.task(w, r)
+	if !ok {
+		return
+	}
+	s.renderEdit(w, r, http.StatusOK, t, formFor(t), "")
+}
+
+func (s *server) renderEdit(w http.ResponseWriter, r *http.Request, code int, t api.Task, f form, problem string) {
+	notes, err := api.Notes(s.db, t.ID)
+	if err != nil && problem == "" {
+		problem = err.Error()
+	}
+	s.render(w, r, "form", code, page{Title: fmt.Sprintf("#%d", t.ID), Form: f, Notes: notes, Error: problem})
+}
+
+func (s *server) save(w http.ResponseWriter, r *http.Request) {
+	t, ok := s.task(w, r)
+	if !ok {
+		return
+	}
+	f := formOf(r)
+	f.ID, f.From = t.ID, t.Status
+	in, problems := f.input()
+	if strings.TrimSpace(f.Text) == "" {
+		problems = append(problems, "text is required")
+	}
+	fields, more := in.Fields(false)
+	if problems = append(problems, more...); len(problems) > 0 {
+		s.renderEdit(w, r, http.StatusBadRequest, t, f, strings.Join(problems, "; "))
+		return
+	}
+	if err := api.Change(s.db, t, f.Version, fields, db.SourceWeb); err != nil {
+		if errors.Is(err, db.ErrModified) {
+			// start over from what is stored now
+			s.renderEdit(w, r, http.StatusConflict, t, formFor(t), "The task was changed meanwhile, this is the current version.")
+			return
+		}
+		if errors.Is(err, api.ErrTransition) {
+			s.renderEdit(w, r, http.StatusBadRequest, t, f, err.Error())
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	http.Redirect(w, r, "/ui/", http.StatusSeeOther)
+}
+
+// complete moves a task to done, or the first terminal status.
+func (s *server) complete(w http.ResponseWriter, r *http.Request) {
+	cfg := config.Get()
+	done, exists := cfg.Status("done")
+	for _, status := range cfg.Statuses {
+		if !exists && status.Terminal {
+			done, exists = status, true
+		}
+	}
+	if !exists {
+		http.Error(w, "no terminal status configured", http.StatusInternalServerError)
+		return
+	}
+	s.setStatus(w, r, done)
+}
+
+func (s *server) move(w http.ResponseWriter, r *http.Request) {
+	status, exists := config.Get().Status(r.FormValue("status"))
+	if !exists {
+		s.back(w, r, fmt.Sprintf("Invalid status %v", r.FormValue("status")))
+		return
+	}
+	s.setStatus(w, r, status)
+}
+
+func (s *server) setStatus(w http.ResponseWriter, r *http.Request, status config.Status) {
+	t, ok := s.task(w, r)
+	if !ok {
+		return
+	}
+	version, err := strconv.ParseInt(r.FormValue("version"), 10, 64)
+	if err != nil {
+		version = t.Version()
+	}
+	err = api.Change(s.db, t, version, map[string]any{"status": status.Value}, db.SourceWeb)
+	switch {
+	case errors.Is(err, db.ErrModified):
+		s.back(w, r, fmt.Sprintf("#%d was changed meanwhile, try again", t.ID))
+	case err != nil:
+		s.back(w, r, err.Error())
+	default:
+		s.back(w, r, "")
+	}
+}
+
+// back redirects to the page a form was posted from.
+func (s *server) back(w http.ResponseWriter, r *http.Request, problem string) {
+	to := r.FormValue("back")
+	if !strings.HasPrefix(to, "/ui/") {
+		to = "/ui/"
+	}
+	if problem != "" {
+		sep := "?"
+		if strings.Contains(to, "?") {
+			sep = "&"
+		}
+		to += sep + "error=" + url.QueryEscape(problem)
+	}
+	http.Redirect(w, r, to, http.StatusSeeOther)
+}
+
+// task loads the task of the {id} in the path, writing the error when
+// there is none.
+func (s *server) task(w http.ResponseWriter, r *http.Request) (api.Task, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return api.Task{}, false
+	}
+	t, err := api.Load(s.db, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		http.NotFound(w, r)
+		return api.Task{}, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return api.Task{}, false
+	}
+	return t, true
+}
+
+// color maps the color of a status or priority name to CSS.
+func color(name string) string {
+	cfg := config.Get()
+	c := "gray"
+	if s, exists := cfg.Status(name); exists {
+		c = s.Color
+	} else if p, exists := cfg.Priority(name); exists {
+		c = p.Color
+	}
+	switch c {
+	case "yellow":
+		return "goldenrod"
+	case "cyan":
+		return "darkcyan"
+	}
+	return c
+}
+
+// next lists the statuses a task in status may move to, status first.
+func next(status string) []config.Status {
+	cfg := config.Get()
+	from, exists := cfg.Status(status)
+	if !exists {
+		return cfg.Statuses
+	}
+	statuses := []config.Status{from}
+	for _, s := range cfg.Statuses {
+		if s.Value != from.Value && from.Allows(s) {
+			statuses = append(statuses, s)
+		}
+	}
+	return statuses
+}
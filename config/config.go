@@ -0,0 +1,377 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type Config struct {
+	// ArchiveAfterDays is how long a done task stays in the list before
+	// the daemon archives it. 0 disables auto archiving.
+	ArchiveAfterDays int `json:"archive_after_days"`
+	// Statuses is the task workflow. Values are stored in todos.status.
+	Statuses []Status `json:"statuses"`
+	// Priorities is the priority scale. Weights are stored in
+	// todos.priority and sort from low to high.
+	Priorities []Priority `json:"priorities"`
+	// DefaultPriority is the priority of new tasks given none.
+	DefaultPriority string `json:"default_priority"`
+	// TimerLimit is how long a timer may run before the daemon nags.
+	TimerLimit Duration `json:"timer_limit"`
+	// Notifiers the daemon delivers every notification to.
+	Notifiers []Notifier `json:"notifiers"`
+	// Digests are the scheduled summaries of open tasks.
+	Digests []Digest `json:"digests"`
+	// Escalations re-notify overdue tasks. A task is at the step with the
+	// longest After it has been overdue for.
+	Escalations []Escalation `json:"escalations"`
+	// Calendar is when the daemon may notify. Notifications outside of it
+	// are queued until the next allowed time.
+	Calendar Calendar `json:"calendar"`
+	// OpenCommand runs when "Open" is clicked on a reminder, {id} is
+	// replaced by the task id. Empty opens todo show in a terminal.
+	OpenCommand string `json:"open_command"`
+	// Backup is where the daemon keeps daily backups.
+	Backup Backup `json:"backup"`
+	// API is the HTTP API todo serve runs, and the daemon when Addr is set.
+	API API `json:"api"`
+}
+
+type API struct {
+	Addr  string `json:"addr"`  // e.g. "127.0.0.1:8080"
+	Token string `json:"token"` // required as a bearer token when set
+}
+
+type Backup struct {
+	Dir  string `json:"dir"`  // empty is ~/.todo-backups
+	Keep int    `json:"keep"` // daily backups kept, 0 disables them
+}
+
+// Directory returns Dir, or the default.
+func (b Backup) Directory() string {
+	if b.Dir != "" {
+		return b.Dir
+	}
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".todo-backups")
+}
+
+type Digest struct {
+	Name     string       `json:"name"`
+	Schedule string       `json:"schedule"` // cron expression, e.g. "0 9 * * 1-5"
+	Title    string       `json:"title"`
+	Filter   DigestFilter `json:"filter"`
+	// Notifiers are names of configured notifiers. Empty uses all.
+	Notifiers []string `json:"notifiers"`
+}
+
+type DigestFilter struct {
+	Status   []string `json:"status"`   // empty means every open status
+	Priority string   `json:"priority"` // e.g. ">=high"
+	Tag      string   `json:"tag"`
+	Project  string   `json:"project"`
+	Due      string   `json:"due"` // overdue (default), today, week or any
+}
+
+type Calendar struct {
+	// WorkingHours by weekday, e.g. {"mon": "09:00-17:00"}, several
+	// ranges separated by commas. Days not listed are off. Empty allows
+	// every day.
+	WorkingHours map[string]string `json:"working_hours"`
+	// Holidays is an .ics file whose events are days off.
+	Holidays string `json:"holidays"`
+	// QuietHours are quiet every day, e.g. ["22:00-07:00"].
+	QuietHours []string `json:"quiet_hours"`
+}
+
+type Escalation struct {
+	Priority string   `json:"priority"` // e.g. ">=high"
+	After    Duration `json:"after"`    // how long overdue
+	Every    Duration `json:"every"`    // repeat interval, 0 notifies once
+	Urgency  string   `json:"urgency"`  // low, normal or critical
+	// Notifiers are names of configured notifiers. Empty uses all.
+	Notifiers []string `json:"notifiers"`
+}
+
+// Notifier configures a notification backend. Type is one of
+// notify-send, dbus, zenity, bell, wall, log, webhook, ntfy or smtp.
+type Notifier struct {
+	Name string `json:"name"` // defaults to the type
+	Type string `json:"type"`
+	// log
+	Path string `json:"path,omitempty"`
+	// webhook and ntfy
+	URL   string `json:"url,omitempty"`
+	Token string `json:"token,omitempty"`
+	// smtp
+	Host     string   `json:"host,omitempty"`
+	Port     int      `json:"port,omitempty"`
+	Username string   `json:"username,omitempty"`
+	Password string   `json:"password,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       []string `json:"to,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "1h30m".
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+type Status struct {
+	Name  string `json:"name"`
+	Value int    `json:"value"`
+	Color string `json:"color"`
+	// Terminal statuses are finished: they are not reminded and are
+	// archived by the daemon.
+	Terminal bool `json:"terminal"`
+	// Transitions lists the statuses a task may move to. Empty allows any.
+	Transitions []string `json:"transitions"`
+}
+
+// current is replaced as a whole when the daemon reloads, while its jobs
+// and handlers read it.
+var current atomic.Pointer[Config]
+
+func init() {
+	current.Store(defaults())
+}
+
+func defaults() *Config {
+	return &Config{
+		ArchiveAfterDays: 14,
+		DefaultPriority:  "medium",
+		Backup:           Backup{Keep: 7},
+		TimerLimit:       Duration(4 * time.Hour),
+		Notifiers:        []Notifier{{Type: "notify-send"}},
+		Digests: []Digest{
+			{Name: "morning", Schedule: "0 9 * * *", Title: "Good Morning!"},
+			{Name: "evening", Schedule: "0 17 * * *", Title: "Good Evening!"},
+		},
+		Escalations: []Escalation{
+			{Priority: ">=high", After: Duration(time.Hour), Every: Duration(30 * time.Minute), Urgency: "critical"},
+		},
+		Statuses: []Status{
+			{Name: "pending", Value: 1, Color: "yellow",
+				Transitions: []string{"processing", "blocked", "waiting", "review", "done", "cancelled"}},
+			{Name: "processing", Value: 2, Color: "cyan",
+				Transitions: []string{"pending", "blocked", "waiting", "review", "done", "cancelled"}},
+			{Name: "done", Value: 3, Color: "green", Terminal: true,
+				Transitions: []string{"pending", "processing"}},
+			{Name: "blocked", Value: 4, Color: "red",
+				Transitions: []string{"pending", "processing", "waiting", "cancelled"}},
+			{Name: "review", Value: 5, Color: "magenta",
+				Transitions: []string{"processing", "blocked", "done", "cancelled"}},
+			{Name: "waiting", Value: 6, Color: "blue",
+				Transitions: []string{"pending", "processing", "blocked", "cancelled"}},
+			{Name: "cancelled", Value: 7, Color: "gray", Terminal: true,
+				Transitions: []string{"pending"}},
+		},
+		Priorities: []Priority{
+			{Name: "none", Weight: 0, Color: "gray", Aliases: []string{"-"}},
+			{Name: "low", Weight: 1, Color: "blue", Aliases: []string{"!", "l", "p3"}},
+			{Name: "medium", Weight: 2, Color: "yellow", Aliases: []string{"!!", "m", "p2"}},
+			{Name: "high", Weight: 3, Color: "red", Aliases: []string{"!!!", "h", "p1"}},
+			{Name: "urgent", Weight: 4, Color: "magenta", Aliases: []string{"!!!!", "u", "p0"}},
+		},
+	}
+}
+
+// Path is the location of the config file, ~/.todo.json.
+func Path() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".todo.json")
+}
+
+// Load reads the config file and makes it the current config.
+func Load(path string) (*Config, error) {
+	cfg, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+	Set(cfg)
+	return cfg, nil
+}
+
+// Read reads the config file, with the defaults for what it leaves out.
+// A missing file is not an error.
+func Read(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return defaults(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// parse decodes a config into a zero one and then takes the defaults for
+// the fields that are not given. Decoding on top of the defaults would
+// keep default values in list elements, such as the transitions of a
+// status, that the file replaces.
+func parse(data []byte) (*Config, error) {
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	if err := fillDefaults(reflect.ValueOf(cfg).Elem(), reflect.ValueOf(defaults()).Elem(), data); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// fillDefaults sets the fields of the struct v that the JSON object data
+// has no key for to the ones of def. Objects given are filled in the same
+// way, lists and maps given are used as they are.
+func fillDefaults(v, def reflect.Value, data []byte) error {
+	var given map[string]json.RawMessage
+	if err := json.Unmarshal(data, &given); err != nil {
+		return err
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		raw, exists := given[name]
+		switch {
+		case !exists:
+			v.Field(i).Set(def.Field(i))
+		case t.Field(i).Type.Kind() == reflect.Struct && strings.HasPrefix(string(raw), "{"):
+			if err := fillDefaults(v.Field(i), def.Field(i), raw); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Get returns the current config.
+func Get() *Config {
+	return current.Load()
+}
+
+// Set makes cfg the current config.
+func Set(cfg *Config) {
+	current.Store(cfg)
+}
+
+type Priority struct {
+	Name    string   `json:"name"`
+	Weight  int      `json:"weight"`
+	Color   string   `json:"color"`
+	Aliases []string `json:"aliases"`
+}
+
+// NotifiersNamed returns the configured notifiers with the given names,
+// or all of them when names is empty.
+func (c *Config) NotifiersNamed(names []string) ([]Notifier, error) {
+	if len(names) == 0 {
+		return c.Notifiers, nil
+	}
+	var notifiers []Notifier
+	for _, name := range names {
+		i := slices.IndexFunc(c.Notifiers, func(n Notifier) bool {
+			return n.Name == name || (n.Name == "" && n.Type == name)
+		})
+		if i < 0 {
+			return nil, fmt.Errorf("unknown notifier %q", name)
+		}
+		notifiers = append(notifiers, c.Notifiers[i])
+	}
+	return notifiers, nil
+}
+
+// Status finds a status by name or by its stored value.
+func (c *Config) Status(nameOrValue string) (Status, bool) {
+	for _, s := range c.Statuses {
+		if s.Name == nameOrValue || strconv.Itoa(s.Value) == nameOrValue {
+			return s, true
+		}
+	}
+	return Status{}, false
+}
+
+// Priority finds a priority by name, alias or weight. Names and aliases
+// are case insensitive.
+func (c *Config) Priority(nameOrWeight string) (Priority, bool) {
+	for _, p := range c.Priorities {
+		if strings.EqualFold(p.Name, nameOrWeight) || strconv.Itoa(p.Weight) == nameOrWeight {
+			return p, true
+		}
+		for _, alias := range p.Aliases {
+			if strings.EqualFold(alias, nameOrWeight) {
+				return p, true
+			}
+		}
+	}
+	return Priority{}, false
+}
+
+// NewStatus returns the status of new tasks, the first configured one.
+func (c *Config) NewStatus() Status {
+	if len(c.Statuses) == 0 {
+		return Status{}
+	}
+	return c.Statuses[0]
+}
+
+// NewPriority returns the priority of new tasks given none, the default
+// one or, when it is not configured, the lowest.
+func (c *Config) NewPriority() Priority {
+	if p, exists := c.Priority(c.DefaultPriority); exists {
+		return p
+	}
+	var lowest Priority
+	for i, p := range c.Priorities {
+		if i == 0 || p.Weight < lowest.Weight {
+			lowest = p
+		}
+	}
+	return lowest
+}
+
+// TerminalStatuses returns the stored values of every terminal status.
+func (c *Config) TerminalStatuses() []int {
+	var values []int
+	for _, s := range c.Statuses {
+		if s.Terminal {
+			values = append(values, s.Value)
+		}
+	}
+	return values
+}
+
+// Allows reports whether a task in status s may move to next.
+func (s Status) Allows(next Status) bool {
+	if s.Value == next.Value || len(s.Transitions) == 0 {
+		return true
+	}
+	return slices.Contains(s.Transitions, next.Name)
+}
@@ -0,0 +1,491 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ArchiveVersion is the version of the backup format, raised when an
+// older todo could not read a newer archive.
+const ArchiveVersion = 1
+
+// Restore modes.
+const (
+	// Merge keeps the stored rows and adds the ones of the archive that
+	// are missing. Todos are matched by uuid and the last updated wins.
+	Merge = "merge"
+	// Replace empties every table first.
+	Replace = "replace"
+)
+
+// Archive is every table of the database, rows keyed by column name.
+type Archive struct {
+	Version int                         `json:"version"`
+	Schema  int                         `json:"schema"` // pragma user_version
+	Created time.Time                   `json:"created"`
+	Tables  map[string][]map[string]any `json:"tables"`
+}
+
+// Dump reads every table from a snapshot taken with the online backup
+// API, so writes of the daemon during the dump are either all in it or
+// not at all.
+func Dump(db *sql.DB) (*Archive, error) {
+	ctx := context.Background()
+	snapshot, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	defer snapshot.Close()
+	// every connection to :memory: is a database of its own
+	conn, err := snapshot.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	src, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	err = conn.Raw(func(dest any) error {
+		return src.Raw(func(from any) error {
+			backup, err := dest.(*sqlite3.SQLiteConn).Backup("main", from.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Close()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("backup: %w", err)
+	}
+	src.Close()
+
+	a := &Archive{Version: ArchiveVersion, Created: time.Now(), Tables: map[string][]map[string]any{}}
+	if err := conn.QueryRowContext(ctx, "pragma user_version").Scan(&a.Schema); err != nil {
+		return nil, err
+	}
+	tables, err := columns(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	for table, cols := range tables {
+		rows, err := dumpTable(ctx, conn, table, cols, "")
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", table, err)
+		}
+		a.Tables[table] = rows
+	}
+	return a, nil
+}
+
+type column struct {
+	name     string
+	datetime bool // declared as a date, read as the stored text
+	pk       bool
+}
+
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// columns returns the columns of every table but sqlite's own.
+func columns(ctx context.Context, q querier) (map[string][]column, error) {
+	rows, err := q.QueryContext(ctx, "select name from sqlite_master where type = 'table' and name not like 'sqlite_%'")
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	tables := map[string][]column{}
+	for _, name := range names {
+		cols, err := tableColumns(ctx, q, name)
+		if err != nil {
+			return nil, err
+		}
+		tables[name] = cols
+	}
+	return tables, nil
+}
+
+// tableColumns returns the columns of table.
+func tableColumns(ctx context.Context, q querier, table string) ([]column, error) {
+	rows, err := q.QueryContext(ctx, "select name, type, pk from pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cols []column
+	for rows.Next() {
+		var c column
+		var typ string
+		var pk int
+		if err := rows.Scan(&c.name, &typ, &pk); err != nil {
+			return nil, err
+		}
+		typ = strings.ToLower(typ)
+		c.datetime = strings.Contains(typ, "date") || strings.Contains(typ, "time")
+		c.pk = pk > 0
+		cols = append(cols, c)
+	}
+	return cols, rows.Err()
+}
+
+// selectList selects cols, the dates as the stored text: the driver would
+// parse them into a time.Time, turning a date into a date and time.
+func selectList(cols []column) string {
+	exprs := make([]string, len(cols))
+	for i, c := range cols {
+		exprs[i] = c.name
+		if c.datetime {
+			exprs[i] = c.name + " || '' as " + c.name
+		}
+	}
+	return strings.Join(exprs, ", ")
+}
+
+// dumpTable reads the rows of table, those matching where when it is not
+// empty.
+func dumpTable(ctx context.Context, q querier, table string, cols []column, where string, args ...any) ([]map[string]any, error) {
+	rows, err := q.QueryContext(ctx, "select "+selectList(cols)+" from "+table+where+" order by rowid", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	list := []map[string]any{}
+	for rows.Next() {
+		values := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := map[string]any{}
+		for i, c := range cols {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			row[c.name] = values[i]
+		}
+		list = append(list, row)
+	}
+	return list, rows.Err()
+}
+
+// WriteArchive writes an archive as indented JSON.
+func WriteArchive(w io.Writer, a *Archive) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a)
+}
+
+// ReadArchive reads an archive written by WriteArchive. Numbers are
+// int64 unless they have a fraction.
+func ReadArchive(r io.Reader) (*Archive, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var a Archive
+	if err := dec.Decode(&a); err != nil {
+		return nil, err
+	}
+	if a.Version == 0 || a.Tables == nil {
+		return nil, fmt.Errorf("not a todo backup")
+	}
+	if a.Version > ArchiveVersion {
+		return nil, fmt.Errorf("backup version %d is newer than this todo reads (%d)", a.Version, ArchiveVersion)
+	}
+	for _, rows := range a.Tables {
+		for _, row := range rows {
+			for col, v := range row {
+				n, ok := v.(json.Number)
+				if !ok {
+					continue
+				}
+				if i, err := n.Int64(); err == nil {
+					row[col] = i
+				} else if f, err := n.Float64(); err == nil {
+					row[col] = f
+				}
+			}
+		}
+	}
+	return &a, nil
+}
+
+// Restore writes an archive into the database in one transaction, see
+// Merge and Replace. Restoring the same archive again changes nothing.
+// It returns the number of rows written per table.
+func Restore(db *sql.DB, a *Archive, mode string) (map[string]int, error) {
+	if mode != Merge && mode != Replace {
+		return nil, fmt.Errorf("unknown restore mode %q", mode)
+	}
+	ctx := context.Background()
+	var schema int
+	if err := db.QueryRow("pragma user_version").Scan(&schema); err != nil {
+		return nil, err
+	}
+	if a.Schema > schema {
+		return nil, fmt.Errorf("backup schema %d is newer than the database (%d), update todo first", a.Schema, schema)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	tables, err := columns(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	for table, rows := range a.Tables {
+		cols, exists := tables[table]
+		if !exists {
+			return nil, fmt.Errorf("backup table %s is unknown", table)
+		}
+		for _, row := range rows {
+			for col := range row {
+				if !hasColumn(cols, col) {
+					return nil, fmt.Errorf("backup column %s.%s is unknown", table, col)
+				}
+			}
+		}
+	}
+
+	written := map[string]int{}
+	if mode == Replace {
+		for table := range tables {
+			if _, err := tx.Exec("delete from " + table); err != nil {
+				return nil, err
+			}
+		}
+		for table, rows := range a.Tables {
+			for _, row := range rows {
+				if err := insertRow(tx, table, row); err != nil {
+					return nil, fmt.Errorf("%s: %w", table, err)
+				}
+			}
+			written[table] = len(rows)
+		}
+		return written, tx.Commit()
+	}
+
+	o, err := newOperation(tx, SourceRestore)
+	if err != nil {
+		return nil, err
+	}
+	ids, n, err := mergeTodos(tx, a.Tables["todos"], o)
+	if err != nil {
+		return nil, fmt.Errorf("todos: %w", err)
+	}
+	written["todos"] = n
+	names := make([]string, 0, len(a.Tables))
+	for table := range a.Tables {
+		if table != "todos" {
+			names = append(names, table)
+		}
+	}
+	sort.Strings(names)
+	for _, table := range names {
+		n, err := mergeTable(tx, table, tables[table], a.Tables[table], ids)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", table, err)
+		}
+		written[table] = n
+	}
+	return written, tx.Commit()
+}
+
+func hasColumn(cols []column, name string) bool {
+	for _, c := range cols {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func insertRow(tx *sql.Tx, table string, row map[string]any) error {
+	cols := sortedKeys(row)
+	args := make([]any, len(cols))
+	for i, col := range cols {
+		args[i] = row[col]
+	}
+	_, err := tx.Exec("insert into "+table+"("+strings.Join(cols, ",")+") values("+Placeholders(len(cols))+")", args...)
+	return err
+}
+
+// mergeTodos adds the todos that are not stored, matched by uuid or, in
+// archives from before uuids, by text and creation time. A stored todo
+// is updated when the archived one was updated later. Both are recorded
+// in the history as one operation with source restore. It returns the stored id of every
+// archived one.
+func mergeTodos(tx *sql.Tx, rows []map[string]any, o operation) (map[int64]int64, int, error) {
+	ids := map[int64]int64{}
+	written := 0
+	for _, row := range rows {
+		var id int64
+		var updated string
+		var err error
+		if uuid, exists := row["uuid"]; exists && uuid != nil {
+			err = tx.QueryRow("select id, updated_at || '' from todos where uuid = ?", uuid).Scan(&id, &updated)
+		} else {
+			err = tx.QueryRow("select id, updated_at || '' from todos where text = ? and created_at = ?", row["text"], row["created_at"]).Scan(&id, &updated)
+		}
+		archivedID, _ := row["id"].(int64)
+		fields := map[string]any{}
+		for col, v := range row {
+			if col != "id" {
+				fields[col] = v
+			}
+		}
+		switch {
+		case err == sql.ErrNoRows:
+			created, err := insert(tx, fields, o)
+			if err != nil {
+				return nil, written, err
+			}
+			id = int64(created)
+			written++
+		case err != nil:
+			return nil, written, err
+		default:
+			if later, _ := row["updated_at"].(string); later > updated {
+				// the archived updated_at is set after update's own
+				if err := update(tx, []int{int(id)}, fields, o); err != nil {
+					return nil, written, err
+				}
+				written++
+			}
+		}
+		ids[archivedID] = id
+	}
+	return ids, written, nil
+}
+
+// naturalKeys are the columns that tell whether a row is stored, for the
+// tables whose other columns change after the row is written, such as
+// fired_at of a reminder. Other tables are matched on every column.
+var naturalKeys = map[string][]string{
+	"history":      {"todo_id", "op", "diff", "created_at"},
+	"notes":        {"todo_id", "text", "created_at"},
+	"reminders":    {"todo_id", "remind_at", "before", "created_at"},
+	"time_entries": {"todo_id", "started_at"},
+	"dependencies": {"todo_id", "depends_on"},
+	"daemon_state": {"key"},
+}
+
+// mergeTable adds the rows that are not stored yet, with todo ids mapped
+// to the stored todos. Rows get a new id, so only the other columns tell
+// whether a row is stored, the natural key where the table has one.
+func mergeTable(tx *sql.Tx, table string, cols []column, rows []map[string]any, ids map[int64]int64) (int, error) {
+	written := 0
+	for _, archived := range rows {
+		row := map[string]any{}
+		skip := false
+		for col, v := range archived {
+			if col == "id" && len(primaryKey(cols)) == 1 && primaryKey(cols)[0] == "id" {
+				continue
+			}
+			if table == "history" && col == "operation" {
+				// archived changes are not undone, they predate what is stored
+				v = 0
+			}
+			if col == "todo_id" || col == "depends_on" {
+				old, _ := v.(int64)
+				id, exists := ids[old]
+				if !exists {
+					// the todo is not in the archive
+					skip = true
+					break
+				}
+				v = id
+			}
+			row[col] = v
+		}
+		if skip || len(row) == 0 {
+			continue
+		}
+		keys := sortedKeys(row)
+		var conds []string
+		args := make([]any, 0, 2*len(keys))
+		for _, col := range keys {
+			args = append(args, row[col])
+		}
+		match := keys
+		if natural, exists := naturalKeys[table]; exists {
+			match = natural
+		}
+		for _, col := range match {
+			conds = append(conds, col+" is ?")
+			args = append(args, row[col])
+		}
+		res, err := tx.Exec(
+			"insert or ignore into "+table+"("+strings.Join(keys, ",")+") select "+Placeholders(len(keys))+
+				" where not exists (select 1 from "+table+" where "+strings.Join(conds, " and ")+")", args...)
+		if err != nil {
+			return written, err
+		}
+		n, _ := res.RowsAffected()
+		written += int(n)
+	}
+	return written, nil
+}
+
+func primaryKey(cols []column) []string {
+	var pk []string
+	for _, c := range cols {
+		if c.pk {
+			pk = append(pk, c.name)
+		}
+	}
+	return pk
+}
+
+// BackupFile dumps the database to path. The archive is written next to
+// it first, so path is never a partial backup.
+func BackupFile(db *sql.DB, path string) error {
+	a, err := Dump(db)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".todo-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := WriteArchive(f, a); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
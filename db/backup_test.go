@@ -0,0 +1,127 @@
+package db
+
+import (
+	"bytes"
+	"database/sql"
+	"testing"
+)
+
+// filled returns a database with todos, their rows and some history.
+func filled(t *testing.T) *sql.DB {
+	t.Helper()
+	db := open(t)
+	insertTodos(t, db, "a", "b", "c")
+	if err := Update(db, []int{2}, map[string]any{"status": 2}, SourceCLI); err != nil {
+		t.Fatal(err)
+	}
+	exec(t, db, "insert into notes(todo_id, text) values(1, 'first')")
+	exec(t, db, "insert into dependencies(todo_id, depends_on) values(2, 1)")
+	exec(t, db, "insert into reminders(todo_id, remind_at) values(2, '2025-08-01 09:00:00')")
+	exec(t, db, "insert into time_entries(todo_id, started_at, ended_at, duration) values(3, '2025-08-01 09:00:00', '2025-08-01 10:00:00', 3600)")
+	exec(t, db, "insert into daemon_state(key, value) values('last_digest', '2025-08-01')")
+	return db
+}
+
+// archive dumps db and reads it back as restore gets it from a file.
+func archive(t *testing.T, db *sql.DB) *Archive {
+	t.Helper()
+	a, err := Dump(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, a); err != nil {
+		t.Fatal(err)
+	}
+	if a, err = ReadArchive(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func count(t *testing.T, db *sql.DB, table string) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("select count(*) from " + table).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+var restored = []string{"todos", "history", "notes", "dependencies", "reminders", "time_entries", "daemon_state"}
+
+func TestRestoreTwice(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+		// into returns the database restored into, and what happens to
+		// it between the two restores
+		into    func(t *testing.T, from *sql.DB) *sql.DB
+		between func(t *testing.T, db *sql.DB)
+	}{
+		{
+			name: "merge into the same database",
+			mode: Merge,
+			into: func(t *testing.T, from *sql.DB) *sql.DB { return from },
+		},
+		{
+			name: "merge into an empty database",
+			mode: Merge,
+			into: func(t *testing.T, from *sql.DB) *sql.DB { return open(t) },
+		},
+		{
+			name: "merge after a reminder fired",
+			mode: Merge,
+			into: func(t *testing.T, from *sql.DB) *sql.DB { return open(t) },
+			between: func(t *testing.T, db *sql.DB) {
+				exec(t, db, "update reminders set fired_at = '2025-08-01 09:00:05'")
+			},
+		},
+		{
+			name: "replace",
+			mode: Replace,
+			into: func(t *testing.T, from *sql.DB) *sql.DB { return open(t) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := filled(t)
+			a := archive(t, from)
+			db := tt.into(t, from)
+			if _, err := Restore(db, a, tt.mode); err != nil {
+				t.Fatal(err)
+			}
+			if tt.between != nil {
+				tt.between(t, db)
+			}
+			counts := map[string]int{}
+			for _, table := range restored {
+				counts[table] = count(t, db, table)
+			}
+			before := state(t, db)
+
+			written, err := Restore(db, a, tt.mode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.mode == Merge {
+				for table, n := range written {
+					if n != 0 {
+						t.Errorf("second merge wrote %d %s rows", n, table)
+					}
+				}
+			}
+			for _, table := range restored {
+				if n := count(t, db, table); n != counts[table] {
+					t.Errorf("%s has %d rows after the second restore, want %d", table, n, counts[table])
+				}
+			}
+			if got := state(t, db); got != before {
+				t.Errorf("state = %q, want %q", got, before)
+			}
+			if got, want := state(t, db), state(t, from); got != want {
+				t.Errorf("state = %q, want the one backed up %q", got, want)
+			}
+		})
+	}
+}
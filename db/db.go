@@ -2,9 +2,134 @@ package db
 
 import (
 	"database/sql"
+	"fmt"
+	"time"
+
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// migrations are applied in order on top of the base todos table. The
+// index of the last applied migration + 1 is kept in pragma user_version.
+var migrations = []string{
+	`
+      create table if not exists history (
+        id integer primary key autoincrement,
+        todo_id integer not null,
+        op text not null,                     -- create, update, delete
+        diff text not null,                   -- json {"field": [old, new]}
+        source text not null,                 -- cli, daemon, api
+        undone tinyint not null default 0,
+        created_at datetime default current_timestamp
+      );
+      create index if not exists history_todo_id on history(todo_id);
+    `,
+	`
+      alter table todos add column archived_at datetime;
+    `,
+	`
+      -- priorities used to be written as text ("3"), keep them sortable integers
+      update todos set priority = cast(priority as integer) where typeof(priority) != 'integer';
+    `,
+	`
+      alter table todos add column project text;
+      create table if not exists time_entries (
+        id integer primary key autoincrement,
+        todo_id integer not null,
+        started_at datetime not null,
+        ended_at datetime,                    -- null while the timer runs
+        duration integer not null default 0,  -- seconds, set once ended
+        person text,
+        created_at datetime default current_timestamp
+      );
+      create index if not exists time_entries_todo_id on time_entries(todo_id);
+    `,
+	`
+      alter table todos add column estimate integer;  -- seconds
+    `,
+	`
+      create table if not exists reminders (
+        id integer primary key autoincrement,
+        todo_id integer not null,
+        remind_at datetime,                   -- absolute local time
+        before integer,                       -- seconds before due
+        fired_at datetime,
+        created_at datetime default current_timestamp
+      );
+      create index if not exists reminders_todo_id on reminders(todo_id);
+    `,
+	`
+      alter table todos add column due_fired_at datetime;  -- last due notification
+      create table if not exists daemon_state (
+        key text primary key,
+        value text not null
+      );
+    `,
+	`
+      create table if not exists escalations (
+        id integer primary key autoincrement,
+        todo_id integer not null,
+        step integer not null,                -- index in the configured escalations
+        due datetime not null,                -- the due date escalated, a new one starts over
+        notified_at datetime not null
+      );
+      create index if not exists escalations_todo_id on escalations(todo_id);
+    `,
+	`
+      create table if not exists notification_queue (
+        id integer primary key autoincrement,
+        notifiers text not null,              -- comma separated names, empty for all
+        title text not null,
+        body text not null,
+        urgency text not null,
+        queued_at datetime not null
+      );
+    `,
+	`
+      -- a stable identity for syncing with other tools, random version 4
+      alter table todos add column uuid text;
+      update todos set uuid = lower(
+        hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' ||
+        substr('89ab', 1 + abs(random()) % 4, 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6))
+      );
+      create unique index if not exists todos_uuid on todos(uuid);
+      create table if not exists notes (
+        id integer primary key autoincrement,
+        todo_id integer not null,
+        text text not null,
+        created_at datetime default current_timestamp
+      );
+      create index if not exists notes_todo_id on notes(todo_id);
+      create table if not exists dependencies (
+        todo_id integer not null,
+        depends_on integer not null,          -- todo that has to be finished first
+        primary key (todo_id, depends_on)
+      );
+    `,
+	`
+      alter table todos add column recur text;  -- RRULE value, e.g. FREQ=WEEKLY
+    `,
+	`
+      -- one timer runs at a time: end all but the latest running one
+      update time_entries set ended_at = started_at
+      where ended_at is null and id != (select max(id) from time_entries where ended_at is null);
+      create unique index if not exists time_entries_running on time_entries((ended_at is null)) where ended_at is null;
+    `,
+	`
+      -- the step by priority, after and every, the index moves when steps are
+      -- added or reordered; rows from before are matched by step
+      alter table escalations add column key text;
+    `,
+	`
+      alter table history add column related text;  -- json {"table": [row]} a delete took along
+    `,
+	`
+      -- the write a change was part of, undone together; 0 for merged backups
+      alter table history add column operation integer not null default 0;
+      update history set operation = id;
+      create index if not exists history_operation on history(operation);
+    `,
+}
+
 func Connect(path string) (*sql.DB, error) {
 	db, err := sql.Open("sqlite3", path)
 	if err != nil {
@@ -14,7 +139,7 @@ func Connect(path string) (*sql.DB, error) {
       create table if not exists todos (
         id integer primary key autoincrement,
         text text not null,
-        priority tinyint not null default 2,  -- 1 = low, 2 = medium, 3 = high 
+        priority tinyint not null default 2,  -- 1 = low, 2 = medium, 3 = high
         status tinyint not null default 1,    -- 1 = pending, 2 = processing, 3 = done
         due datetime,
         tag text,
@@ -22,5 +147,63 @@ func Connect(path string) (*sql.DB, error) {
         updated_at datetime default current_timestamp
       );
     `)
-	return db, err
+	if err != nil {
+		return db, err
+	}
+	return db, migrate(db)
+}
+
+func migrate(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("pragma user_version").Scan(&version); err != nil {
+		return err
+	}
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return err
+		}
+		// pragma does not accept placeholders
+		if _, err := tx.Exec(fmt.Sprintf("pragma user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RemindAt is the sql expression for when a reminder row r of todo t
+// fires, either its absolute time or relative to the due date.
+const RemindAt = "coalesce(strftime('%Y-%m-%d %H:%M:%S', r.remind_at), datetime(t.due, '-' || r.before || ' seconds'))"
+
+// TimeFormat is how local timestamps are written, e.g. archived_at.
+const TimeFormat = "2006-01-02 15:04:05"
+
+// Local reinterprets a timestamp read from a datetime column, which the
+// driver returns as UTC, in the local time zone it was written in.
+func Local(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.Local)
+}
+
+// UTC formats t as sqlite writes the created_at columns and updated_at,
+// in UTC. The driver returns them in UTC too, unlike the local timestamps
+// Local is for.
+func UTC(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+// Due trims a due date read from the todos table to the minute it is
+// kept to, a date only stays as it is.
+func Due(due string) string {
+	if len(due) > 16 {
+		return due[:16]
+	}
+	return due
 }
@@ -0,0 +1,40 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Snooze postpones the reminders of a todo: the ones that are due now are
+// marked fired and a new reminder is set d from now. A todo that is gone
+// is an error, so no reminder is left without its todo.
+func Snooze(db *sql.DB, todoID int, d time.Duration) (time.Time, error) {
+	now := time.Now()
+	at := now.Add(d)
+	tx, err := db.Begin()
+	if err != nil {
+		return at, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+    update reminders set fired_at = ?
+    where todo_id = ? and fired_at is null and remind_at <= ?
+  `, now.Format(TimeFormat), todoID, now.Format(TimeFormat))
+	if err != nil {
+		return at, err
+	}
+	res, err := tx.Exec(`
+    insert into reminders(todo_id, remind_at) select id, ? from todos where id = ?
+  `, at.Format(TimeFormat), todoID)
+	if err != nil {
+		return at, err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return at, err
+	} else if n == 0 {
+		return at, fmt.Errorf("no todo with id %d", todoID)
+	}
+	return at, tx.Commit()
+}
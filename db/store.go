@@ -0,0 +1,575 @@
+package db
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sources recorded with every history entry.
+const (
+	SourceCLI    = "cli"
+	SourceDaemon = "daemon"
+	SourceAPI    = "api"
+	SourceWeb    = "web"
+	SourceTUI    = "tui"
+	// a merged backup
+	SourceRestore = "restore"
+)
+
+// Row is a full todos row keyed by column name.
+type Row map[string]any
+
+// Diff maps a column name to its [old, new] value.
+type Diff map[string][2]any
+
+// Insert creates a todo from fields and records it in the history. A
+// uuid is generated unless fields has one.
+func Insert(db *sql.DB, fields map[string]any, source string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	o, err := newOperation(tx, source)
+	if err != nil {
+		return 0, err
+	}
+	id, err := insert(tx, fields, o)
+	if err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
+func insert(tx *sql.Tx, fields map[string]any, o operation) (int, error) {
+	if _, exists := fields["uuid"]; !exists {
+		uuid, err := NewUUID()
+		if err != nil {
+			return 0, err
+		}
+		fields["uuid"] = uuid
+	}
+
+	cols := sortedKeys(fields)
+	placeholders := make([]string, len(cols))
+	args := make([]interface{}, len(cols))
+	for i, col := range cols {
+		placeholders[i] = "?"
+		args[i] = fields[col]
+	}
+	query := "insert into todos(" + strings.Join(cols, ",") + ") values(" + strings.Join(placeholders, ",") + ")"
+	res, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	after, err := snapshot(tx, []int{int(id)})
+	if err != nil {
+		return 0, err
+	}
+	if err := record(tx, int(id), "create", diff(nil, after[int(id)]), nil, o); err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+// Update sets fields on every todo in ids and records the changed columns
+// per todo in the history.
+func Update(db *sql.DB, ids []int, fields map[string]any, source string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	o, err := newOperation(tx, source)
+	if err != nil {
+		return err
+	}
+	if err := update(tx, ids, fields, o); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateVersion is Update of one todo, failing with ErrModified unless the
+// todo is still at version.
+func UpdateVersion(db *sql.DB, id int, version int64, fields map[string]any, source string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := checkVersion(tx, id, version); err != nil {
+		return err
+	}
+	o, err := newOperation(tx, source)
+	if err != nil {
+		return err
+	}
+	if err := update(tx, []int{id}, fields, o); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func update(tx *sql.Tx, ids []int, fields map[string]any, o operation) error {
+	before, err := snapshot(tx, ids)
+	if err != nil {
+		return err
+	}
+	query := "update todos set updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')"
+	var args []interface{}
+	for _, col := range sortedKeys(fields) {
+		query += ", " + col + "=?"
+		args = append(args, fields[col])
+	}
+	placeholders := make([]string, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args = append(args, id)
+	}
+	query += " where id in (" + strings.Join(placeholders, ",") + ")"
+	if _, err := tx.Exec(query, args...); err != nil {
+		return err
+	}
+	after, err := snapshot(tx, ids)
+	if err != nil {
+		return err
+	}
+	for id, row := range after {
+		d := diff(before[id], row)
+		delete(d, "updated_at")
+		if len(d) == 0 {
+			continue
+		}
+		if err := record(tx, id, "update", d, nil, o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete removes every todo in ids and keeps their last state in the
+// history so they can be restored by Undo.
+func Delete(db *sql.DB, ids []int, source string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	o, err := newOperation(tx, source)
+	if err != nil {
+		return err
+	}
+	if err := remove(tx, ids, o); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteVersion is Delete of one todo, failing with ErrModified unless the
+// todo is still at version.
+func DeleteVersion(db *sql.DB, id int, version int64, source string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := checkVersion(tx, id, version); err != nil {
+		return err
+	}
+	o, err := newOperation(tx, source)
+	if err != nil {
+		return err
+	}
+	if err := remove(tx, []int{id}, o); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func remove(tx *sql.Tx, ids []int, o operation) error {
+	before, err := snapshot(tx, ids)
+	if err != nil {
+		return err
+	}
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	for id, row := range before {
+		rows, err := dependentRows(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := record(tx, id, "delete", diff(row, nil), rows, o); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.Exec("delete from todos where id in ("+strings.Join(placeholders, ",")+")", args...); err != nil {
+		return err
+	}
+	return deleteDependents(tx, ids)
+}
+
+// dependents are the tables whose rows belong to a todo, with the
+// condition that selects them. They go with the todo and come back when
+// its delete is undone.
+var dependents = []struct{ table, where string }{
+	{"notes", "todo_id = ?"},
+	{"reminders", "todo_id = ?"},
+	{"time_entries", "todo_id = ?"},
+	{"escalations", "todo_id = ?"},
+	{"dependencies", "todo_id = ?1 or depends_on = ?1"},
+}
+
+// dependentRows returns the rows of todo id in the dependents tables.
+func dependentRows(tx *sql.Tx, id int) (map[string][]Row, error) {
+	ctx := context.Background()
+	tables := map[string][]Row{}
+	for _, d := range dependents {
+		cols, err := tableColumns(ctx, tx, d.table)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := dumpTable(ctx, tx, d.table, cols, " where "+d.where, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			tables[d.table] = append(tables[d.table], row)
+		}
+	}
+	return tables, nil
+}
+
+func deleteDependents(tx *sql.Tx, ids []int) error {
+	for _, id := range ids {
+		for _, d := range dependents {
+			if _, err := tx.Exec("delete from "+d.table+" where "+d.where, id); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ErrModified is returned when a todo changed since the version a write
+// was based on.
+var ErrModified = errors.New("todo was modified")
+
+// VersionQuery is the version of a todo, updated_at in milliseconds since
+// the epoch, which every Update moves.
+const VersionQuery = "cast(round((julianday(updated_at) - 2440587.5) * 86400000) as integer)"
+
+// Touch moves the version of todo id without a history entry, for changes
+// to what the version covers besides its columns, such as the time logged.
+func Touch(tx *sql.Tx, id int) error {
+	_, err := tx.Exec("update todos set updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') where id = ?", id)
+	return err
+}
+
+func checkVersion(tx *sql.Tx, id int, version int64) error {
+	var current int64
+	if err := tx.QueryRow("select "+VersionQuery+" from todos where id = ?", id).Scan(&current); err != nil {
+		return err
+	}
+	if current != version {
+		return ErrModified
+	}
+	return nil
+}
+
+// snapshot reads the full rows of ids, normalising values so they can be
+// compared and stored as json. Dates are kept as stored, so a date-only
+// due is written back as one.
+func snapshot(tx *sql.Tx, ids []int) (map[int]Row, error) {
+	rows := make(map[int]Row, len(ids))
+	if len(ids) == 0 {
+		return rows, nil
+	}
+	columns, err := tableColumns(context.Background(), tx, "todos")
+	if err != nil {
+		return nil, err
+	}
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	res, err := tx.Query("select "+selectList(columns)+" from todos where id in ("+strings.Join(placeholders, ",")+")", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Close()
+
+	cols, err := res.Columns()
+	if err != nil {
+		return nil, err
+	}
+	for res.Next() {
+		values := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := res.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		row := make(Row, len(cols))
+		for i, col := range cols {
+			row[col] = normalize(values[i])
+		}
+		rows[int(row["id"].(int64))] = row
+	}
+	return rows, res.Err()
+}
+
+func normalize(v any) any {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case float64:
+		// json numbers decode as float64
+		if v == float64(int64(v)) {
+			return int64(v)
+		}
+	}
+	return v
+}
+
+func diff(before, after Row) Diff {
+	d := Diff{}
+	for col, v := range after {
+		if old := before[col]; old != v {
+			d[col] = [2]any{old, v}
+		}
+	}
+	for col, v := range before {
+		if _, exists := after[col]; !exists {
+			d[col] = [2]any{v, nil}
+		}
+	}
+	return d
+}
+
+// operation is one write, such as a delete of several todos. Its history
+// entries share its id and are undone together.
+type operation struct {
+	id     int64
+	source string
+}
+
+func newOperation(tx *sql.Tx, source string) (operation, error) {
+	o := operation{source: source}
+	err := tx.QueryRow("select coalesce(max(operation), 0) + 1 from history").Scan(&o.id)
+	return o, err
+}
+
+// record adds a history entry. related are the dependents rows a delete
+// took with it, nil for other ops.
+func record(tx *sql.Tx, todoID int, op string, d Diff, related map[string][]Row, o operation) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	var relatedData any
+	if len(related) > 0 {
+		b, err := json.Marshal(related)
+		if err != nil {
+			return err
+		}
+		relatedData = string(b)
+	}
+	_, err = tx.Exec(`
+    insert into history(todo_id, op, diff, related, source, operation) values(?,?,?,?,?,?)
+  `, todoID, op, string(data), relatedData, o.source, o.id)
+	return err
+}
+
+// NewUUID returns a random version 4 UUID.
+func NewUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// Placeholders returns n comma separated "?" for an "in (...)" clause.
+func Placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Change is a single history entry.
+type Change struct {
+	ID        int
+	Operation int64 // shared by the changes of one write
+	TodoID    int
+	Op        string
+	Diff      Diff
+	Related   map[string][]Row // rows a delete took with the todo
+	Source    string
+	Undone    bool
+	CreatedAt time.Time
+}
+
+// History returns every change recorded for a todo, oldest first.
+func History(db *sql.DB, todoID int) ([]Change, error) {
+	rows, err := db.Query(`
+    select id, operation, todo_id, op, diff, coalesce(related, ''), source, undone, created_at
+    from history
+    where todo_id = ?
+    order by id
+  `, todoID)
+	if err != nil {
+		return nil, err
+	}
+	return scanChanges(rows)
+}
+
+func scanChanges(rows *sql.Rows) ([]Change, error) {
+	defer rows.Close()
+
+	var changes []Change
+	for rows.Next() {
+		var c Change
+		var data, related string
+		if err := rows.Scan(&c.ID, &c.Operation, &c.TodoID, &c.Op, &data, &related, &c.Source,
+			&c.Undone, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &c.Diff); err != nil {
+			return nil, err
+		}
+		for col, v := range c.Diff {
+			c.Diff[col] = [2]any{normalize(v[0]), normalize(v[1])}
+		}
+		if related != "" {
+			if err := json.Unmarshal([]byte(related), &c.Related); err != nil {
+				return nil, err
+			}
+			for _, rows := range c.Related {
+				for _, row := range rows {
+					for col, v := range row {
+						row[col] = normalize(v)
+					}
+				}
+			}
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// Undo reverts the last n operations that are not undone yet, newest
+// first, in a single transaction. An operation is one write as a whole,
+// such as an update of several todos. Deleted todos are restored with
+// their original id.
+func Undo(db *sql.DB, n int) ([]Change, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+    select id, operation, todo_id, op, diff, coalesce(related, ''), source, undone, created_at
+    from history
+    where undone = 0 and operation in (
+      select operation from history
+      where undone = 0 and operation > 0
+      group by operation
+      order by operation desc
+      limit ?
+    )
+    order by id desc
+  `, n)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := scanChanges(rows)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range changes {
+		if err := revert(tx, c); err != nil {
+			return nil, fmt.Errorf("undo #%d: %w", c.ID, err)
+		}
+		if _, err := tx.Exec("update history set undone = 1 where id = ?", c.ID); err != nil {
+			return nil, err
+		}
+	}
+	return changes, tx.Commit()
+}
+
+func revert(tx *sql.Tx, c Change) error {
+	cols := sortedKeys(c.Diff)
+	var args []interface{}
+	switch c.Op {
+	case "create":
+		if _, err := tx.Exec("delete from todos where id = ?", c.TodoID); err != nil {
+			return err
+		}
+		return deleteDependents(tx, []int{c.TodoID})
+	case "update":
+		query := "update todos set updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')"
+		for _, col := range cols {
+			query += ", " + col + "=?"
+			args = append(args, c.Diff[col][0])
+		}
+		args = append(args, c.TodoID)
+		_, err := tx.Exec(query+" where id = ?", args...)
+		return err
+	case "delete":
+		placeholders := make([]string, len(cols))
+		for i, col := range cols {
+			placeholders[i] = "?"
+			args = append(args, c.Diff[col][0])
+		}
+		query := "insert into todos(" + strings.Join(cols, ",") + ") values(" + strings.Join(placeholders, ",") + ")"
+		if _, err := tx.Exec(query, args...); err != nil {
+			return err
+		}
+		// a dependency between two todos deleted together is in both
+		for table, rows := range c.Related {
+			for _, row := range rows {
+				cols := sortedKeys(row)
+				args := make([]any, len(cols))
+				for i, col := range cols {
+					args[i] = row[col]
+				}
+				query := "insert or ignore into " + table + "(" + strings.Join(cols, ",") + ") values(" + Placeholders(len(cols)) + ")"
+				if _, err := tx.Exec(query, args...); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown op %q", c.Op)
+}
@@ -0,0 +1,190 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// open connects to a new database in a temporary directory.
+func open(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := Connect(filepath.Join(t.TempDir(), "todos.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// exec runs statements the store has no function for, such as adding a
+// note.
+func exec(t *testing.T, db *sql.DB, query string, args ...any) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// state describes the todos and the rows that belong to them.
+func state(t *testing.T, db *sql.DB) string {
+	t.Helper()
+	var parts []string
+	for _, q := range []string{
+		"select 'todo ' || id || ' ' || text || ' ' || status from todos order by id",
+		"select 'note ' || todo_id || ' ' || text from notes order by id",
+		"select 'dep ' || todo_id || '>' || depends_on from dependencies order by todo_id, depends_on",
+		"select 'reminder ' || todo_id from reminders order by id",
+		"select 'entry ' || todo_id from time_entries order by id",
+	} {
+		rows, err := db.Query(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var s string
+			if err := rows.Scan(&s); err != nil {
+				t.Fatal(err)
+			}
+			parts = append(parts, s)
+		}
+		rows.Close()
+	}
+	return strings.Join(parts, ", ")
+}
+
+func insertTodos(t *testing.T, db *sql.DB, texts ...string) {
+	t.Helper()
+	for _, text := range texts {
+		if _, err := Insert(db, map[string]any{"text": text}, SourceCLI); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestUndo(t *testing.T) {
+	tests := []struct {
+		name    string
+		run     func(t *testing.T, db *sql.DB)
+		n       int
+		changes int // history entries undone
+		want    string
+	}{
+		{
+			name: "update of several todos",
+			run: func(t *testing.T, db *sql.DB) {
+				insertTodos(t, db, "a", "b", "c")
+				if err := Update(db, []int{1, 2}, map[string]any{"status": 3}, SourceCLI); err != nil {
+					t.Fatal(err)
+				}
+			},
+			n:       1,
+			changes: 2,
+			want:    "todo 1 a 1, todo 2 b 1, todo 3 c 1",
+		},
+		{
+			name: "delete of several todos with their rows",
+			run: func(t *testing.T, db *sql.DB) {
+				insertTodos(t, db, "a", "b")
+				exec(t, db, "insert into notes(todo_id, text) values(1, 'first')")
+				exec(t, db, "insert into dependencies(todo_id, depends_on) values(2, 1)")
+				exec(t, db, "insert into reminders(todo_id, before) values(2, 3600)")
+				exec(t, db, "insert into time_entries(todo_id, started_at, ended_at, duration) values(1, '2025-08-01 09:00:00', '2025-08-01 10:00:00', 3600)")
+				if err := Delete(db, []int{1, 2}, SourceCLI); err != nil {
+					t.Fatal(err)
+				}
+			},
+			n:       1,
+			changes: 2,
+			want:    "todo 1 a 1, todo 2 b 1, note 1 first, dep 2>1, reminder 2, entry 1",
+		},
+		{
+			name: "two operations",
+			run: func(t *testing.T, db *sql.DB) {
+				insertTodos(t, db, "a", "b")
+				if err := Update(db, []int{1, 2}, map[string]any{"status": 2}, SourceCLI); err != nil {
+					t.Fatal(err)
+				}
+				if err := Delete(db, []int{1}, SourceCLI); err != nil {
+					t.Fatal(err)
+				}
+			},
+			n:       2,
+			changes: 3,
+			want:    "todo 1 a 1, todo 2 b 1",
+		},
+		{
+			name: "create takes its rows along",
+			run: func(t *testing.T, db *sql.DB) {
+				insertTodos(t, db, "a", "b")
+				exec(t, db, "insert into reminders(todo_id, before) values(2, 3600)")
+				exec(t, db, "insert into time_entries(todo_id, started_at) values(2, '2025-08-01 09:00:00')")
+			},
+			n:       1,
+			changes: 1,
+			want:    "todo 1 a 1",
+		},
+		{
+			name: "more than there is",
+			run: func(t *testing.T, db *sql.DB) {
+				insertTodos(t, db, "a")
+			},
+			n:       5,
+			changes: 1,
+			want:    "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := open(t)
+			tt.run(t, db)
+			changes, err := Undo(db, tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(changes) != tt.changes {
+				t.Errorf("undid %d changes, want %d", len(changes), tt.changes)
+			}
+			if got := state(t, db); got != tt.want {
+				t.Errorf("state = %q, want %q", got, tt.want)
+			}
+			// the undone changes stay in the history, marked undone
+			var undone int
+			if err := db.QueryRow("select count(*) from history where undone = 1").Scan(&undone); err != nil {
+				t.Fatal(err)
+			}
+			if undone != tt.changes {
+				t.Errorf("%d changes marked undone, want %d", undone, tt.changes)
+			}
+		})
+	}
+}
+
+func TestHistoryOperation(t *testing.T) {
+	db := open(t)
+	insertTodos(t, db, "a", "b")
+	if err := Update(db, []int{1, 2}, map[string]any{"text": "c"}, SourceAPI); err != nil {
+		t.Fatal(err)
+	}
+	var operations []string
+	for id := 1; id <= 2; id++ {
+		changes, err := History(db, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 2 {
+			t.Fatalf("todo %d has %d changes, want 2", id, len(changes))
+		}
+		c := changes[1]
+		if c.Op != "update" || c.Source != SourceAPI || c.Diff["text"][1] != "c" {
+			t.Errorf("todo %d change = %+v", id, c)
+		}
+		operations = append(operations, fmt.Sprint(changes[0].Operation, c.Operation))
+	}
+	// each create is an operation of its own, the update is one for both
+	if want := []string{"1 3", "2 3"}; strings.Join(operations, ",") != strings.Join(want, ",") {
+		t.Errorf("operations = %v, want %v", operations, want)
+	}
+}